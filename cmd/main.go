@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/got-many-wheels/spoderman/internal/app"
@@ -10,6 +11,8 @@ import (
 func main() {
 	app := app.New()
 	if err := app.Cli.Run(context.Background(), os.Args); err != nil {
-		panic(err)
+		// exit codes set by the commands are handled by the cli already
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 }
@@ -4,13 +4,19 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"regexp"
 	"slices"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/got-many-wheels/spoderman/internal/config"
 	"github.com/got-many-wheels/spoderman/internal/crawler"
 	"github.com/got-many-wheels/spoderman/internal/logger"
+	"github.com/got-many-wheels/spoderman/internal/redact"
+	"github.com/got-many-wheels/spoderman/internal/report"
+	"github.com/got-many-wheels/spoderman/internal/store"
 	"github.com/got-many-wheels/spoderman/internal/utils"
 	ucli "github.com/urfave/cli/v3"
 )
@@ -18,6 +24,9 @@ import (
 func Get(cfg *config.Config, logger *logger.Logger) []*ucli.Command {
 	return []*ucli.Command{
 		Crawl(cfg, logger),
+		Report(cfg, logger),
+		Query(cfg, logger),
+		Rescan(cfg, logger),
 	}
 }
 
@@ -25,6 +34,8 @@ func Crawl(cfg *config.Config, logger *logger.Logger) *ucli.Command {
 	cmd := &ucli.Command{
 		Name:  "crawl",
 		Usage: "Start the crawling process",
+		// headers and user agents commonly contain commas
+		DisableSliceFlagSeparator: true,
 		Flags: []ucli.Flag{
 			&ucli.IntFlag{
 				Name:    "depth",
@@ -86,11 +97,200 @@ func Crawl(cfg *config.Config, logger *logger.Logger) *ucli.Command {
 				Usage:   "Interval between each job",
 				Aliases: []string{"it"},
 			},
+			&ucli.StringFlag{
+				Name:    "proxy",
+				Value:   "",
+				Usage:   "HTTP(S) or SOCKS5 proxy url, separated by commas to rotate between multiple proxies.",
+				Aliases: []string{"p"},
+			},
+			&ucli.StringFlag{
+				Name:  "no-proxy",
+				Value: "",
+				Usage: "Domains that bypass the proxy, separated by commas.",
+			},
+			&ucli.BoolFlag{
+				Name:  "probe",
+				Value: *cfg.Probe.Enabled,
+				Usage: "Probe every discovered host for well known sensitive paths.",
+			},
+			&ucli.StringFlag{
+				Name:  "probe-wordlist",
+				Value: cfg.Probe.Wordlist,
+				Usage: "File with extra paths to probe, one per line.",
+			},
+			&ucli.StringFlag{
+				Name:  "store",
+				Usage: "SQLite database the results of every run accumulate in.",
+			},
+			&ucli.StringFlag{
+				Name:  "metrics-addr",
+				Usage: "Address the Prometheus metrics are served at during the crawl, under /metrics.",
+			},
+			&ucli.StringSliceFlag{
+				Name:  "log-level",
+				Usage: "Log level, or level of a component in \"component=level\" format: fetch, parse, scan, queue or notify. Can be repeated.",
+			},
+			&ucli.StringFlag{
+				Name:  "log-file",
+				Usage: "File the logs are written to as JSON as well, rotated by size.",
+			},
+			&ucli.BoolFlag{
+				Name:  "access-log",
+				Usage: "Log an access record per response: url, status, bytes, duration, depth and parent page.",
+			},
+			&ucli.StringSliceFlag{
+				Name:  "notify-webhook",
+				Usage: "Webhook url the findings are posted to as JSON as they are found, can be repeated.",
+			},
+			&ucli.StringSliceFlag{
+				Name:  "notify-slack",
+				Usage: "Slack incoming webhook url the findings are posted to as they are found, can be repeated.",
+			},
+			&ucli.StringFlag{
+				Name:  "notify-min-severity",
+				Usage: "Least severe findings sent to the notifiers given as flags: critical, high, medium, low or info.",
+			},
+			&ucli.StringFlag{
+				Name:  "otlp-endpoint",
+				Usage: "OTLP/HTTP endpoint the spans of the crawled pages are exported to, such as http://localhost:4318.",
+			},
+			&ucli.BoolFlag{
+				Name:  "no-progress",
+				Usage: "Disable the live progress view and the progress log lines.",
+			},
+			&ucli.StringFlag{
+				Name:  "har",
+				Usage: "HAR capture whose entries are scanned as already fetched pages, its cookies seed the cookie jar.",
+			},
+			&ucli.BoolFlag{
+				Name:  "har-seed",
+				Usage: "Crawl the urls linked from the entries of the HAR capture.",
+			},
+			&ucli.StringFlag{
+				Name:  "warc",
+				Usage: "Directory the requests and responses of every fetched page are archived in, as WARC files.",
+			},
+			&ucli.StringFlag{
+				Name:  "redact",
+				Usage: "How secret values are masked in every output: full, partial or hash.",
+			},
+			&ucli.BoolFlag{
+				Name:  "unredacted",
+				Usage: "Write secret values as they are found, in plain text.",
+			},
+			&ucli.StringFlag{
+				Name:  "report",
+				Usage: "Report generated from the results once the crawl is done: html.",
+			},
+			&ucli.StringFlag{
+				Name:  "min-severity",
+				Usage: "Least severe findings kept, the others are discarded: critical, high, medium, low or info.",
+			},
+			&ucli.StringFlag{
+				Name:  "fail-on",
+				Usage: "Exit with code 1 when a finding is at least this severe, for CI: critical, high, medium, low or info.",
+			},
+			&ucli.BoolFlag{
+				Name:  "pii",
+				Usage: "Look for personal data as well: phone numbers, IBANs, credit cards, national ids and ip addresses.",
+			},
+			&ucli.StringFlag{
+				Name:  "pii-countries",
+				Usage: "Countries whose national ids and phone formats are looked for, separated by commas: us, gb, fr, nl, es or in.",
+			},
+			&ucli.StringFlag{
+				Name:  "graph",
+				Usage: "Link graph formats written to the output, separated by commas: dot, graphml or json.",
+			},
+			&ucli.StringSliceFlag{
+				Name:  "exclude-path",
+				Usage: "Path glob excluded from the crawl, can be repeated.",
+			},
+			&ucli.StringSliceFlag{
+				Name:    "header",
+				Usage:   "Extra request header in \"Name: value\" format, can be repeated.",
+				Aliases: []string{"H"},
+			},
+			&ucli.StringSliceFlag{
+				Name:    "user-agent",
+				Usage:   "User agent, can be repeated to rotate between multiple user agents.",
+				Aliases: []string{"ua"},
+			},
+			&ucli.StringFlag{
+				Name:  "cookie-file",
+				Value: cfg.CookieFile,
+				Usage: "Netscape formatted cookies file to seed the cookie jar with.",
+			},
 		},
 		Action: func(ctx context.Context, c *ucli.Command) error {
+			// the config file is loaded first and the flags are applied on
+			// top of it, a flag given along with -i wins over the file.
+			cfgSrc := c.String("config")
+			if len(cfgSrc) > 0 {
+				currConf, err := config.UnmarshalConfig(cfgSrc)
+				if err != nil {
+					return err
+				}
+				cfg = currConf
+			}
+			if c.IsSet("depth") {
+				cfg.Depth = config.Ptr(c.Int("depth"))
+			}
+			if c.IsSet("workers") {
+				cfg.Workers = config.Ptr(c.Int("workers"))
+			}
+			if c.IsSet("base") {
+				cfg.Base = config.Ptr(c.Bool("base"))
+			}
+			if c.IsSet("output") {
+				cfg.Output = c.String("output")
+			}
+
+			if addr := c.String("metrics-addr"); len(addr) > 0 {
+				cfg.MetricsAddr = addr
+			}
+			for _, level := range c.StringSlice("log-level") {
+				if component, lvl, found := strings.Cut(level, "="); found {
+					if cfg.Log.Components == nil {
+						cfg.Log.Components = map[string]string{}
+					}
+					cfg.Log.Components[strings.TrimSpace(component)] = strings.TrimSpace(lvl)
+				} else {
+					cfg.Log.Level = level
+				}
+			}
+			if logFile := c.String("log-file"); len(logFile) > 0 {
+				cfg.Log.File = logFile
+			}
+			if c.Bool("access-log") {
+				cfg.Log.Access = config.Ptr(true)
+			}
+			if err := logger.Configure(cfg.Log); err != nil {
+				return err
+			}
+			defer logger.Close()
+			if endpoint := c.String("otlp-endpoint"); len(endpoint) > 0 {
+				cfg.Tracing.Endpoint = endpoint
+			}
+			for _, url := range c.StringSlice("notify-webhook") {
+				cfg.Notify.Notifiers = append(cfg.Notify.Notifiers, config.Notifier{Type: "webhook", Url: url, MinSeverity: c.String("notify-min-severity")})
+			}
+			for _, url := range c.StringSlice("notify-slack") {
+				cfg.Notify.Notifiers = append(cfg.Notify.Notifiers, config.Notifier{Type: "slack", Url: url, MinSeverity: c.String("notify-min-severity")})
+			}
+			if c.Bool("no-progress") {
+				cfg.Progress.Enabled = config.Ptr(false)
+			}
+			if har := c.String("har"); len(har) > 0 {
+				cfg.Har.File = har
+			}
+			if c.Bool("har-seed") {
+				cfg.Har.Seed = config.Ptr(true)
+			}
+
 			var urls []string
 			fUrl, fUrlFile := c.String("url"), c.String("url-file")
-			if len(fUrl) == 0 && len(fUrlFile) == 0 {
+			if len(fUrl) == 0 && len(fUrlFile) == 0 && len(cfg.Har.File) == 0 {
 				return errors.New("Target url is required")
 			} else {
 				if len(fUrl) > 0 {
@@ -117,7 +317,7 @@ func Crawl(cfg *config.Config, logger *logger.Logger) *ucli.Command {
 				}
 			}
 
-			if c.Int("interval") != 0 {
+			if c.IsSet("interval") {
 				cfg.Interval = config.Ptr(c.Int("interval"))
 			}
 
@@ -131,18 +331,347 @@ func Crawl(cfg *config.Config, logger *logger.Logger) *ucli.Command {
 				cfg.DisallowedDomains = append(cfg.DisallowedDomains, zp.Split(disallowedDomains, -1)...)
 			}
 
-			cfgSrc := c.String("config")
-			if len(cfgSrc) > 0 {
+			proxies, noProxy := c.String("proxy"), c.String("no-proxy")
+			if len(proxies) > 0 {
+				zp := regexp.MustCompile(` *, *`)
+				cfg.Proxy.Pool = append(cfg.Proxy.Pool, zp.Split(proxies, -1)...)
+			}
+			if len(noProxy) > 0 {
+				zp := regexp.MustCompile(` *, *`)
+				cfg.Proxy.NoProxy = append(cfg.Proxy.NoProxy, zp.Split(noProxy, -1)...)
+			}
+
+			for _, h := range c.StringSlice("header") {
+				name, value, found := strings.Cut(h, ":")
+				if !found {
+					return fmt.Errorf("invalid header %q, expected \"Name: value\"", h)
+				}
+				cfg.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+			}
+			cfg.UserAgents = append(cfg.UserAgents, c.StringSlice("user-agent")...)
+			if cookieFile := c.String("cookie-file"); len(cookieFile) > 0 {
+				cfg.CookieFile = cookieFile
+			}
+
+			if c.Bool("probe") {
+				cfg.Probe.Enabled = config.Ptr(true)
+			}
+			if wordlist := c.String("probe-wordlist"); len(wordlist) > 0 {
+				cfg.Probe.Wordlist = wordlist
+			}
+			if c.Bool("pii") {
+				cfg.Pii.Enabled = config.Ptr(true)
+			}
+			if countries := c.String("pii-countries"); len(countries) > 0 {
+				zp := regexp.MustCompile(` *, *`)
+				cfg.Pii.Countries = zp.Split(countries, -1)
+			}
+			if graph := c.String("graph"); len(graph) > 0 {
+				zp := regexp.MustCompile(` *, *`)
+				cfg.Graph = zp.Split(graph, -1)
+			}
+			for _, p := range c.StringSlice("exclude-path") {
+				cfg.Scope = append(cfg.Scope, config.ScopeRule{Action: "exclude", Path: p})
+			}
+
+			if err := applyRedaction(c, cfg); err != nil {
+				return err
+			}
+			if db := c.String("store"); len(db) > 0 {
+				cfg.Store = db
+			}
+			if dir := c.String("warc"); len(dir) > 0 {
+				cfg.Warc.Dir = dir
+			}
+			if format := c.String("report"); len(format) > 0 {
+				cfg.Report = format
+			}
+			if err := checkReport(cfg); err != nil {
+				return err
+			}
+			if err := applySeverities(c, cfg); err != nil {
+				return err
+			}
+
+			crawler, err := crawler.New(logger, slices.Compact(urls), *cfg)
+			if err != nil {
+				return err
+			}
+			if err := crawler.Do(); err != nil {
+				return err
+			}
+			if err := generateReport(cfg, logger); err != nil {
+				return err
+			}
+			return failOn(cfg, crawler.MostSevere())
+		},
+	}
+	return cmd
+}
+
+// Rescan runs the current rules over archived responses instead of crawling.
+func Rescan(cfg *config.Config, logger *logger.Logger) *ucli.Command {
+	return &ucli.Command{
+		Name:      "rescan",
+		Usage:     "Run the current rules over the responses stored in WARC or HAR archives",
+		ArgsUsage: "<archive>...",
+		Flags: []ucli.Flag{
+			&ucli.StringFlag{
+				Name:    "config",
+				Usage:   "Set config file, defaults to set flag values or empty",
+				Aliases: []string{"i"},
+			},
+			&ucli.StringFlag{
+				Name:    "output",
+				Usage:   "Output location for secret results.",
+				Aliases: []string{"o"},
+			},
+			&ucli.StringFlag{
+				Name:  "store",
+				Usage: "SQLite database the results of every run accumulate in.",
+			},
+			&ucli.StringFlag{
+				Name:  "redact",
+				Usage: "How secret values are masked in every output: full, partial or hash.",
+			},
+			&ucli.BoolFlag{
+				Name:  "unredacted",
+				Usage: "Write secret values as they are found, in plain text.",
+			},
+			&ucli.StringFlag{
+				Name:  "report",
+				Usage: "Report generated from the results once the rescan is done: html.",
+			},
+			&ucli.StringFlag{
+				Name:  "min-severity",
+				Usage: "Least severe findings kept, the others are discarded: critical, high, medium, low or info.",
+			},
+			&ucli.StringFlag{
+				Name:  "fail-on",
+				Usage: "Exit with code 1 when a finding is at least this severe, for CI: critical, high, medium, low or info.",
+			},
+		},
+		Action: func(ctx context.Context, c *ucli.Command) error {
+			if cfgSrc := c.String("config"); len(cfgSrc) > 0 {
 				currConf, err := config.UnmarshalConfig(cfgSrc)
 				if err != nil {
 					return err
 				}
 				cfg = currConf
 			}
+			if output := c.String("output"); len(output) > 0 {
+				cfg.Output = output
+			}
+			if db := c.String("store"); len(db) > 0 {
+				cfg.Store = db
+			}
+			if format := c.String("report"); len(format) > 0 {
+				cfg.Report = format
+			}
+			if err := applyRedaction(c, cfg); err != nil {
+				return err
+			}
+			if err := checkReport(cfg); err != nil {
+				return err
+			}
+			if err := applySeverities(c, cfg); err != nil {
+				return err
+			}
+			if err := logger.Configure(cfg.Log); err != nil {
+				return err
+			}
+			defer logger.Close()
+			// archives are read as they were written
+			cfg.Warc.Dir = ""
 
-			crawler := crawler.New(logger, slices.Compact(urls), *cfg)
-			return crawler.Do()
+			crawler, err := crawler.New(logger, nil, *cfg)
+			if err != nil {
+				return err
+			}
+			if err := crawler.Rescan(c.Args().Slice()); err != nil {
+				return err
+			}
+			if err := generateReport(cfg, logger); err != nil {
+				return err
+			}
+			return failOn(cfg, crawler.MostSevere())
+		},
+	}
+}
+
+// applyRedaction applies the --redact and --unredacted flags, redaction can
+// only be disabled from the command line.
+func applyRedaction(c *ucli.Command, cfg *config.Config) error {
+	if mode := c.String("redact"); len(mode) > 0 {
+		cfg.Redaction.Mode = mode
+	}
+	if c.Bool("unredacted") {
+		cfg.Redaction.Mode = redact.ModeNone
+	} else if cfg.Redaction.Mode == redact.ModeNone {
+		return errors.New("redaction can only be disabled with the --unredacted flag")
+	}
+	return nil
+}
+
+// applySeverities applies the --min-severity and --fail-on flags, the fail-on
+// severity is checked before anything is crawled.
+func applySeverities(c *ucli.Command, cfg *config.Config) error {
+	if severity := c.String("min-severity"); len(severity) > 0 {
+		cfg.MinSeverity = severity
+	}
+	if severity := c.String("fail-on"); len(severity) > 0 {
+		cfg.FailOn = severity
+	}
+	if len(cfg.FailOn) > 0 && !slices.Contains(config.Severities, cfg.FailOn) {
+		return fmt.Errorf("unknown fail-on severity %q, severities are %s", cfg.FailOn, strings.Join(config.Severities, ", "))
+	}
+	return nil
+}
+
+// failOn exits with code 1 when the most severe finding is at least as severe
+// as the fail-on severity, errors exit with another code.
+func failOn(cfg *config.Config, mostSevere string) error {
+	if len(cfg.FailOn) == 0 || len(mostSevere) == 0 {
+		return nil
+	}
+	if slices.Index(config.Severities, mostSevere) <= slices.Index(config.Severities, cfg.FailOn) {
+		return ucli.Exit(fmt.Sprintf("Found %s severity findings, failing on %s and above", mostSevere, cfg.FailOn), 1)
+	}
+	return nil
+}
+
+func checkReport(cfg *config.Config) error {
+	if len(cfg.Report) == 0 {
+		return nil
+	}
+	if !report.ValidFormat(cfg.Report) {
+		return fmt.Errorf("unknown report format %q", cfg.Report)
+	}
+	if len(cfg.Output) == 0 {
+		return errors.New("Please provide an output to generate the report from")
+	}
+	return nil
+}
+
+func generateReport(cfg *config.Config, logger *logger.Logger) error {
+	if len(cfg.Report) == 0 {
+		return nil
+	}
+	dst, err := report.Generate(cfg.Output, cfg.Report, nil)
+	if err != nil {
+		return err
+	}
+	logger.Info().Msg(fmt.Sprintf("Report written to %s", dst))
+	return nil
+}
+
+func Report(cfg *config.Config, logger *logger.Logger) *ucli.Command {
+	return &ucli.Command{
+		Name:      "report",
+		Usage:     "Generate a report from the results stored in an output directory",
+		ArgsUsage: "<output>",
+		Flags: []ucli.Flag{
+			&ucli.StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Value:   "html",
+				Usage:   "Report format: html.",
+			},
+			&ucli.StringSliceFlag{
+				Name:  "category",
+				Usage: "Only report the findings of a category, such as pii for privacy audits, can be repeated.",
+			},
+		},
+		Action: func(ctx context.Context, c *ucli.Command) error {
+			dir := c.Args().First()
+			if len(dir) == 0 {
+				dir = cfg.Output
+			}
+			if len(dir) == 0 {
+				return errors.New("Please provide the output directory of a crawl")
+			}
+			dst, err := report.Generate(dir, c.String("format"), c.StringSlice("category"))
+			if err != nil {
+				return err
+			}
+			logger.Info().Msg(fmt.Sprintf("Report written to %s", dst))
+			return nil
+		},
+	}
+}
+
+func Query(cfg *config.Config, logger *logger.Logger) *ucli.Command {
+	var presets strings.Builder
+	for _, p := range store.Presets {
+		fmt.Fprintf(&presets, "\n   %-18s %s", p.Name, p.Usage)
+	}
+	return &ucli.Command{
+		Name:        "query",
+		Usage:       "Query the results store",
+		ArgsUsage:   "<preset>",
+		Description: "Presets:" + presets.String(),
+		Flags: []ucli.Flag{
+			&ucli.StringFlag{
+				Name:    "store",
+				Aliases: []string{"s"},
+				Usage:   "SQLite database to query.",
+			},
+			&ucli.StringFlag{
+				Name:  "sql",
+				Usage: "Raw SQL query, in place of a preset.",
+			},
+			&ucli.IntFlag{
+				Name:  "run",
+				Usage: "Run queried by the presets, defaults to the latest one.",
+			},
+		},
+		Action: func(ctx context.Context, c *ucli.Command) error {
+			src := c.String("store")
+			if len(src) == 0 {
+				src = cfg.Store
+			}
+			if len(src) == 0 {
+				return errors.New("Please provide the store to query")
+			}
+			if _, err := os.Stat(src); err != nil {
+				return err
+			}
+			db, err := store.Open(src)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			query := c.String("sql")
+			var args []any
+			if len(query) == 0 {
+				name := c.Args().First()
+				preset, ok := store.LookupPreset(name)
+				if !ok {
+					return fmt.Errorf("unknown preset %q, presets are:%s", name, presets.String())
+				}
+				query = preset.SQL
+				if strings.Contains(query, "?") {
+					run := int64(c.Int("run"))
+					if run == 0 {
+						if run, err = db.LatestRun(); err != nil {
+							return err
+						}
+					}
+					args = append(args, run)
+				}
+			}
+
+			columns, rows, err := db.Query(query, args...)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, strings.Join(columns, "\t"))
+			for _, row := range rows {
+				fmt.Fprintln(w, strings.Join(row, "\t"))
+			}
+			return w.Flush()
 		},
 	}
-	return cmd
 }
@@ -14,11 +14,271 @@ const (
 	DEFAULT_WORKERS  = 10
 	DEFAULT_BASE     = false
 	DEFAULT_VERBOSE  = false
+
+	DEFAULT_MAX_REPEATED_SEGMENTS = 3
+	DEFAULT_MAX_URLS_PER_PATTERN  = 500
+	DEFAULT_DEDUP_MAX_DISTANCE    = 3
+	DEFAULT_MAX_BODY_SIZE         = 10 * 1024 * 1024
+	DEFAULT_SCAN_BINARY           = false
+	DEFAULT_ARCHIVE_MAX_SIZE      = 100 * 1024 * 1024
+	DEFAULT_ARCHIVE_MAX_RATIO     = 100
+	DEFAULT_ARCHIVE_MAX_ENTRIES   = 10000
+	DEFAULT_ARCHIVE_MAX_DEPTH     = 3
+	DEFAULT_PROBE                 = false
+	DEFAULT_GIT                   = true
+	DEFAULT_GIT_MAX_OBJECTS       = 100000
+	DEFAULT_SEVERITY              = "medium"
+	DEFAULT_CATEGORY              = "credential"
+	DEFAULT_PII                   = false
+	DEFAULT_REDACTION_MODE        = "partial"
+	DEFAULT_REDACTION_KEEP_FIRST  = 4
+	DEFAULT_REDACTION_KEEP_LAST   = 2
+	DEFAULT_WARC_MAX_SIZE         = 1024 * 1024 * 1024
+	DEFAULT_HAR_SEED              = false
+	DEFAULT_HAR_COOKIES           = true
+	DEFAULT_PROGRESS              = true
+	DEFAULT_PROGRESS_INTERVAL     = 10
+	DEFAULT_TRACING_SAMPLE_RATIO  = 1.0
+	DEFAULT_LOG_MAX_SIZE          = 100 * 1024 * 1024
+	DEFAULT_LOG_MAX_BACKUPS       = 5
+	DEFAULT_LOG_ACCESS            = false
+	DEFAULT_NOTIFY_BATCH_SIZE     = 20
+	DEFAULT_NOTIFY_INTERVAL       = 5
+	DEFAULT_NOTIFY_RETRIES        = 3
 )
 
+var DEFAULT_STRIP_PARAMS = []string{"utm_*", "fbclid", "gclid", "sessionid", "jsessionid", "phpsessid", "sid"}
+
 type Rule struct {
-	Name    string `json:"name"    yaml:"name"`
-	Pattern string `json:"pattern" yaml:"pattern"`
+	Name     string   `json:"name"     yaml:"name"`
+	Pattern  string   `json:"pattern"  yaml:"pattern"`
+	Severity string   `json:"severity" yaml:"severity,omitempty"` // one of Severities, defaults to DEFAULT_SEVERITY
+	Category string   `json:"category" yaml:"category,omitempty"` // one of Categories, defaults to DEFAULT_CATEGORY
+	CWE      string   `json:"cwe"      yaml:"cwe,omitempty"`      // such as CWE-798, defaults to the CWE of the category
+	Keywords []string `json:"keywords" yaml:"keywords,omitempty"` // raise the confidence of the matches they precede
+}
+
+// Severities of the findings, from the most to the least severe.
+var Severities = []string{"critical", "high", "medium", "low", "info"}
+
+// Confidences that a finding is what its rule looks for, from the highest.
+var Confidences = []string{"high", "medium", "low"}
+
+// Categories of the rules: secrets granting access, personal data,
+// infrastructure details and exposed endpoints.
+var Categories = []string{"credential", "pii", "infra", "endpoint"}
+
+// ProxyRule routes every host matching Domain (wildcards allowed) through URL.
+// An URL of "direct" bypasses any proxy for that host.
+type ProxyRule struct {
+	Domain string `yaml:"domain"`
+	URL    string `yaml:"url"`
+}
+
+type Proxy struct {
+	URL        string      `yaml:"url,omitempty"`
+	Pool       []string    `yaml:"pool,omitempty"` // rotated in round-robin fashion
+	Username   string      `yaml:"username,omitempty"`
+	Password   string      `yaml:"password,omitempty"`
+	NoProxy    []string    `yaml:"noProxy,omitempty"`
+	Rules      []ProxyRule `yaml:"rules,omitempty"`
+	DisableEnv bool        `yaml:"disableEnv"` // ignore HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+}
+
+// DomainHeaders are sent on top of the default headers to every host matching
+// Domain (wildcards allowed).
+type DomainHeaders struct {
+	Domain  string            `yaml:"domain"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+type Cookie struct {
+	Domain string `yaml:"domain"`
+	Name   string `yaml:"name"`
+	Value  string `yaml:"value"`
+	Path   string `yaml:"path,omitempty"`
+}
+
+// LoggedOut describes responses that indicate an expired session, any match
+// triggers a new login.
+type LoggedOut struct {
+	Status  []int  `yaml:"status,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"` // regex matched against the response body
+	Url     string `yaml:"url,omitempty"`     // regex matched against the final url, eg. redirects to the login page
+}
+
+// Auth configures the authentication used while crawling. Secrets are never
+// stored in the config, only the names of the environment variables holding
+// them.
+type Auth struct {
+	Type    string   `yaml:"type"`              // form, basic, digest, bearer or oauth2
+	Domains []string `yaml:"domains,omitempty"` // hosts receiving the credentials, defaults to the target hosts
+
+	UsernameEnv string `yaml:"usernameEnv,omitempty"`
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+
+	// form login
+	LoginUrl      string            `yaml:"loginUrl,omitempty"`
+	UsernameField string            `yaml:"usernameField,omitempty"`
+	PasswordField string            `yaml:"passwordField,omitempty"`
+	Fields        map[string]string `yaml:"fields,omitempty"` // extra form fields
+
+	// bearer
+	TokenEnv string `yaml:"tokenEnv,omitempty"`
+
+	// oauth2 client credentials
+	TokenUrl        string   `yaml:"tokenUrl,omitempty"`
+	ClientIdEnv     string   `yaml:"clientIdEnv,omitempty"`
+	ClientSecretEnv string   `yaml:"clientSecretEnv,omitempty"`
+	Scopes          []string `yaml:"scopes,omitempty"`
+
+	LoggedOut     *LoggedOut `yaml:"loggedOut,omitempty"`
+	LogoutPattern string     `yaml:"logoutPattern,omitempty"` // regex of links excluded from the crawl
+}
+
+// ScopeRule includes or excludes urls, every criteria set on a rule has to
+// match for the rule to apply. Rules are evaluated in order and the first
+// matching rule decides, urls matching no rule are allowed.
+type ScopeRule struct {
+	Name       string   `yaml:"name,omitempty"`
+	Action     string   `yaml:"action"` // include or exclude
+	Scheme     []string `yaml:"scheme,omitempty"`
+	Port       []string `yaml:"port,omitempty"`
+	Path       string   `yaml:"path,omitempty"` // glob
+	PathRegex  string   `yaml:"pathRegex,omitempty"`
+	Query      []string `yaml:"query,omitempty"` // names of query parameters that must be present
+	Extensions []string `yaml:"extensions,omitempty"`
+}
+
+// Canonical controls how urls are normalized before checking whether they
+// have been visited.
+type Canonical struct {
+	StripParams       []string `yaml:"stripParams,omitempty"` // query parameters to drop, wildcards allowed
+	KeepTrailingSlash *bool    `yaml:"keepTrailingSlash,omitempty"`
+}
+
+// Traps holds the crawler trap heuristics, 0 disables a heuristic.
+type Traps struct {
+	MaxRepeatedSegments *int `yaml:"maxRepeatedSegments,omitempty"` // occurrences of the same path segment
+	MaxUrlsPerPattern   *int `yaml:"maxUrlsPerPattern,omitempty"`   // urls sharing the same path shape
+}
+
+// Dedup skips pages whose content was already seen under another url.
+type Dedup struct {
+	Enabled     *bool `yaml:"enabled,omitempty"`
+	MaxDistance *int  `yaml:"maxDistance,omitempty"` // simhash bits that may differ between near duplicates, 0 only skips exact duplicates
+}
+
+// BodySize limits the bytes read from responses whose content type matches
+// Type (wildcards allowed, eg. "image/*").
+type BodySize struct {
+	Type string `yaml:"type"`
+	Size int64  `yaml:"size"`
+}
+
+// Archives limits the inspection of compressed responses, decompression
+// stops as soon as one of the limits is exceeded.
+type Archives struct {
+	MaxTotalSize *int64 `yaml:"maxTotalSize,omitempty"` // decompressed bytes per archive
+	MaxRatio     *int   `yaml:"maxRatio,omitempty"`     // decompressed to compressed bytes ratio
+	MaxEntries   *int   `yaml:"maxEntries,omitempty"`
+	MaxDepth     *int   `yaml:"maxDepth,omitempty"` // nested archives
+}
+
+// Probe requests well known sensitive paths on every discovered host.
+type Probe struct {
+	Enabled  *bool    `yaml:"enabled,omitempty"`
+	Paths    []string `yaml:"paths,omitempty"`    // replaces the built-in paths
+	Wordlist string   `yaml:"wordlist,omitempty"` // file with extra paths, one per line
+}
+
+// Git rebuilds the exposed .git directories found while crawling and scans
+// every blob of their history.
+type Git struct {
+	Enabled    *bool `yaml:"enabled,omitempty"`
+	MaxObjects *int  `yaml:"maxObjects,omitempty"` // loose objects requested per repository
+}
+
+// Redaction masks the secret values written to every output, values are
+// only written as they are with the --unredacted flag.
+type Redaction struct {
+	Mode      string `yaml:"mode,omitempty"`      // full, partial or hash
+	KeepFirst *int   `yaml:"keepFirst,omitempty"` // runes kept in partial mode
+	KeepLast  *int   `yaml:"keepLast,omitempty"`
+	Salt      string `yaml:"salt,omitempty"` // keys the fingerprints and hashes
+}
+
+// Warc writes the requests and responses of every fetched page to WARC
+// files under Dir.
+type Warc struct {
+	Dir     string `yaml:"dir,omitempty"`
+	MaxSize *int64 `yaml:"maxSize,omitempty"` // bytes after which a new file is started
+}
+
+// Progress shows a live view of the crawl on terminals, progress log lines
+// every Interval seconds otherwise.
+type Progress struct {
+	Enabled  *bool `yaml:"enabled,omitempty"`
+	Interval *int  `yaml:"interval,omitempty"`
+}
+
+// Tracing exports a span per crawled page to an OTLP/HTTP endpoint such as
+// http://localhost:4318.
+type Tracing struct {
+	Endpoint    string            `yaml:"endpoint,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	SampleRatio *float64          `yaml:"sampleRatio,omitempty"` // ratio of the pages traced
+}
+
+// Notifier pushes findings as they are found to a webhook, a Slack
+// compatible webhook or by email.
+type Notifier struct {
+	Type        string `yaml:"type"`                  // webhook, slack or email
+	MinSeverity string `yaml:"minSeverity,omitempty"` // findings below are not sent
+
+	// webhook and slack
+	Url      string            `yaml:"url,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Template string            `yaml:"template,omitempty"` // json body, a text/template over the batch
+
+	// email
+	SmtpAddr    string   `yaml:"smtpAddr,omitempty"` // host:port
+	UsernameEnv string   `yaml:"usernameEnv,omitempty"`
+	PasswordEnv string   `yaml:"passwordEnv,omitempty"`
+	From        string   `yaml:"from,omitempty"`
+	To          []string `yaml:"to,omitempty"`
+}
+
+// Notify batches the findings sent by the notifiers.
+type Notify struct {
+	Notifiers []Notifier `yaml:"notifiers,omitempty"`
+	BatchSize *int       `yaml:"batchSize,omitempty"` // findings sent at once
+	Interval  *int       `yaml:"interval,omitempty"`  // seconds a batch waits for more findings
+	Retries   *int       `yaml:"retries,omitempty"`   // attempts after a failed send
+}
+
+// Log configures the logs on top of the --verbose flag.
+type Log struct {
+	Level      string            `yaml:"level,omitempty"`      // trace, debug, info, warn or error
+	Components map[string]string `yaml:"components,omitempty"` // level per component: fetch, parse, scan or queue
+	File       string            `yaml:"file,omitempty"`       // json logs are written to the file as well
+	MaxSize    *int64            `yaml:"maxSize,omitempty"`    // bytes after which the file is rotated
+	MaxBackups *int              `yaml:"maxBackups,omitempty"` // rotated files kept
+	Access     *bool             `yaml:"access,omitempty"`     // access log record per response
+}
+
+// Har imports a HAR capture: its entries are scanned as already fetched pages.
+type Har struct {
+	File    string `yaml:"file,omitempty"`
+	Seed    *bool  `yaml:"seed,omitempty"`    // crawl the urls the entries link to
+	Cookies *bool  `yaml:"cookies,omitempty"` // seed the cookie jar with the cookies of the entries
+}
+
+// Pii enables the rules finding personal data: phone numbers, IBANs, credit
+// cards, national ids and ip addresses.
+type Pii struct {
+	Enabled   *bool    `yaml:"enabled,omitempty"`
+	Countries []string `yaml:"countries,omitempty"` // national ids and phone formats looked for, every supported country when empty
 }
 
 type Config struct {
@@ -30,7 +290,51 @@ type Config struct {
 	DisallowedDomains []string `yaml:"disallowedDomains,omitempty"`
 	Output            string   `yaml:"output"`
 	Rules             []Rule   `yaml:"rules"`
+	Pii               *Pii     `yaml:"pii,omitempty"`
 	Interval          *int     `json:"interval"` // interval in miliseconds
+	Proxy             *Proxy   `yaml:"proxy,omitempty"`
+
+	Headers       map[string]string `yaml:"headers,omitempty"`
+	DomainHeaders []DomainHeaders   `yaml:"domainHeaders,omitempty"`
+	UserAgents    []string          `yaml:"userAgents,omitempty"` // rotated in round-robin fashion
+	Cookies       []Cookie          `yaml:"cookies,omitempty"`
+	CookieFile    string            `yaml:"cookieFile,omitempty"` // netscape cookies.txt format
+
+	Auth *Auth `yaml:"auth,omitempty"`
+
+	Scope []ScopeRule `yaml:"scope,omitempty"`
+
+	Canonical *Canonical `yaml:"canonical,omitempty"`
+	Traps     *Traps     `yaml:"traps,omitempty"`
+
+	Dedup *Dedup `yaml:"dedup,omitempty"`
+
+	MaxBodySize *int64     `yaml:"maxBodySize,omitempty"` // bytes read from a response when no body size matches
+	BodySizes   []BodySize `yaml:"bodySizes,omitempty"`   // first matching content type wins
+	ScanBinary  *bool      `yaml:"scanBinary,omitempty"`
+
+	Processors map[string]bool `yaml:"processors,omitempty"` // html, javascript, css, json, xml, text, archive or binary
+	Archives   *Archives       `yaml:"archives,omitempty"`
+
+	Probe *Probe `yaml:"probe,omitempty"`
+	Git   *Git   `yaml:"git,omitempty"`
+
+	Graph  []string `yaml:"graph,omitempty"`  // link graph formats: dot, graphml or json
+	Report string   `yaml:"report,omitempty"` // report format written along the results: html
+	Store  string   `yaml:"store,omitempty"`  // sqlite database the results of every run accumulate in
+
+	MinSeverity string `yaml:"minSeverity,omitempty"` // findings less severe are discarded
+	FailOn      string `yaml:"failOn,omitempty"`      // exit with code 1 when a finding is at least this severe
+
+	Redaction *Redaction `yaml:"redaction,omitempty"`
+	Warc      *Warc      `yaml:"warc,omitempty"`
+	Har       *Har       `yaml:"har,omitempty"`
+	Progress  *Progress  `yaml:"progress,omitempty"`
+
+	MetricsAddr string   `yaml:"metricsAddr,omitempty"` // address the prometheus metrics are served at, under /metrics
+	Tracing     *Tracing `yaml:"tracing,omitempty"`
+	Log         *Log     `yaml:"log,omitempty"`
+	Notify      *Notify  `yaml:"notify,omitempty"`
 }
 
 func Ptr[T any](v T) *T { return &v }
@@ -46,6 +350,74 @@ func New() *Config {
 		Output:            "",
 		Rules:             []Rule{},
 		Interval:          Ptr(int(DEFAULT_INTERVAL)),
+		Proxy:             &Proxy{},
+		Headers:           map[string]string{},
+		DomainHeaders:     []DomainHeaders{},
+		UserAgents:        []string{},
+		Cookies:           []Cookie{},
+		CookieFile:        "",
+		Scope:             []ScopeRule{},
+		Canonical: &Canonical{
+			StripParams:       DEFAULT_STRIP_PARAMS,
+			KeepTrailingSlash: Ptr(false),
+		},
+		Traps: &Traps{
+			MaxRepeatedSegments: Ptr(DEFAULT_MAX_REPEATED_SEGMENTS),
+			MaxUrlsPerPattern:   Ptr(DEFAULT_MAX_URLS_PER_PATTERN),
+		},
+		Dedup: &Dedup{
+			Enabled:     Ptr(true),
+			MaxDistance: Ptr(DEFAULT_DEDUP_MAX_DISTANCE),
+		},
+		MaxBodySize: Ptr(int64(DEFAULT_MAX_BODY_SIZE)),
+		BodySizes:   []BodySize{},
+		ScanBinary:  Ptr(DEFAULT_SCAN_BINARY),
+		Processors:  map[string]bool{},
+		Archives: &Archives{
+			MaxTotalSize: Ptr(int64(DEFAULT_ARCHIVE_MAX_SIZE)),
+			MaxRatio:     Ptr(DEFAULT_ARCHIVE_MAX_RATIO),
+			MaxEntries:   Ptr(DEFAULT_ARCHIVE_MAX_ENTRIES),
+			MaxDepth:     Ptr(DEFAULT_ARCHIVE_MAX_DEPTH),
+		},
+		Probe: &Probe{
+			Enabled: Ptr(DEFAULT_PROBE),
+		},
+		Git: &Git{
+			Enabled:    Ptr(DEFAULT_GIT),
+			MaxObjects: Ptr(DEFAULT_GIT_MAX_OBJECTS),
+		},
+		Redaction: &Redaction{
+			Mode:      DEFAULT_REDACTION_MODE,
+			KeepFirst: Ptr(DEFAULT_REDACTION_KEEP_FIRST),
+			KeepLast:  Ptr(DEFAULT_REDACTION_KEEP_LAST),
+		},
+		Warc: &Warc{
+			MaxSize: Ptr(int64(DEFAULT_WARC_MAX_SIZE)),
+		},
+		Pii: &Pii{
+			Enabled: Ptr(DEFAULT_PII),
+		},
+		Har: &Har{
+			Seed:    Ptr(DEFAULT_HAR_SEED),
+			Cookies: Ptr(DEFAULT_HAR_COOKIES),
+		},
+		Notify: &Notify{
+			BatchSize: Ptr(DEFAULT_NOTIFY_BATCH_SIZE),
+			Interval:  Ptr(DEFAULT_NOTIFY_INTERVAL),
+			Retries:   Ptr(DEFAULT_NOTIFY_RETRIES),
+		},
+		Log: &Log{
+			MaxSize:    Ptr(int64(DEFAULT_LOG_MAX_SIZE)),
+			MaxBackups: Ptr(DEFAULT_LOG_MAX_BACKUPS),
+			Access:     Ptr(DEFAULT_LOG_ACCESS),
+		},
+		Tracing: &Tracing{
+			SampleRatio: Ptr(DEFAULT_TRACING_SAMPLE_RATIO),
+		},
+		Progress: &Progress{
+			Enabled:  Ptr(DEFAULT_PROGRESS),
+			Interval: Ptr(DEFAULT_PROGRESS_INTERVAL),
+		},
 	}
 }
 
@@ -99,6 +471,230 @@ func UnmarshalConfig(src string) (*Config, error) {
 	if len(temp.Rules) > 0 {
 		cfg.Rules = temp.Rules
 	}
+	if temp.Proxy != nil {
+		cfg.Proxy = temp.Proxy
+	}
+
+	if len(temp.Headers) > 0 {
+		cfg.Headers = temp.Headers
+	}
+	if len(temp.DomainHeaders) > 0 {
+		cfg.DomainHeaders = temp.DomainHeaders
+	}
+	if len(temp.UserAgents) > 0 {
+		cfg.UserAgents = temp.UserAgents
+	}
+	if len(temp.Cookies) > 0 {
+		cfg.Cookies = temp.Cookies
+	}
+	if temp.CookieFile != "" {
+		cfg.CookieFile = temp.CookieFile
+	}
+
+	if temp.Auth != nil {
+		cfg.Auth = temp.Auth
+	}
+
+	if len(temp.Scope) > 0 {
+		cfg.Scope = temp.Scope
+	}
+
+	if temp.Canonical != nil {
+		if temp.Canonical.StripParams != nil {
+			cfg.Canonical.StripParams = temp.Canonical.StripParams
+		}
+		if temp.Canonical.KeepTrailingSlash != nil {
+			cfg.Canonical.KeepTrailingSlash = temp.Canonical.KeepTrailingSlash
+		}
+	}
+	if temp.Traps != nil {
+		if temp.Traps.MaxRepeatedSegments != nil {
+			cfg.Traps.MaxRepeatedSegments = temp.Traps.MaxRepeatedSegments
+		}
+		if temp.Traps.MaxUrlsPerPattern != nil {
+			cfg.Traps.MaxUrlsPerPattern = temp.Traps.MaxUrlsPerPattern
+		}
+	}
+
+	if temp.Dedup != nil {
+		if temp.Dedup.Enabled != nil {
+			cfg.Dedup.Enabled = temp.Dedup.Enabled
+		}
+		if temp.Dedup.MaxDistance != nil {
+			cfg.Dedup.MaxDistance = temp.Dedup.MaxDistance
+		}
+	}
+
+	if temp.MaxBodySize != nil {
+		cfg.MaxBodySize = temp.MaxBodySize
+	}
+	if len(temp.BodySizes) > 0 {
+		cfg.BodySizes = temp.BodySizes
+	}
+	if temp.ScanBinary != nil {
+		cfg.ScanBinary = temp.ScanBinary
+	}
+	if len(temp.Processors) > 0 {
+		cfg.Processors = temp.Processors
+	}
+	if temp.Archives != nil {
+		if temp.Archives.MaxTotalSize != nil {
+			cfg.Archives.MaxTotalSize = temp.Archives.MaxTotalSize
+		}
+		if temp.Archives.MaxRatio != nil {
+			cfg.Archives.MaxRatio = temp.Archives.MaxRatio
+		}
+		if temp.Archives.MaxEntries != nil {
+			cfg.Archives.MaxEntries = temp.Archives.MaxEntries
+		}
+		if temp.Archives.MaxDepth != nil {
+			cfg.Archives.MaxDepth = temp.Archives.MaxDepth
+		}
+	}
+
+	if temp.Probe != nil {
+		if temp.Probe.Enabled != nil {
+			cfg.Probe.Enabled = temp.Probe.Enabled
+		}
+		if len(temp.Probe.Paths) > 0 {
+			cfg.Probe.Paths = temp.Probe.Paths
+		}
+		if temp.Probe.Wordlist != "" {
+			cfg.Probe.Wordlist = temp.Probe.Wordlist
+		}
+	}
+
+	if len(temp.Graph) > 0 {
+		cfg.Graph = temp.Graph
+	}
+	if temp.Report != "" {
+		cfg.Report = temp.Report
+	}
+	if temp.Store != "" {
+		cfg.Store = temp.Store
+	}
+	if temp.MinSeverity != "" {
+		cfg.MinSeverity = temp.MinSeverity
+	}
+	if temp.FailOn != "" {
+		cfg.FailOn = temp.FailOn
+	}
+
+	if temp.Redaction != nil {
+		if temp.Redaction.Mode == "none" {
+			return nil, errors.New("redaction can only be disabled with the --unredacted flag")
+		}
+		if temp.Redaction.Mode != "" {
+			cfg.Redaction.Mode = temp.Redaction.Mode
+		}
+		if temp.Redaction.KeepFirst != nil {
+			cfg.Redaction.KeepFirst = temp.Redaction.KeepFirst
+		}
+		if temp.Redaction.KeepLast != nil {
+			cfg.Redaction.KeepLast = temp.Redaction.KeepLast
+		}
+		if temp.Redaction.Salt != "" {
+			cfg.Redaction.Salt = temp.Redaction.Salt
+		}
+	}
+
+	if temp.MetricsAddr != "" {
+		cfg.MetricsAddr = temp.MetricsAddr
+	}
+
+	if temp.Notify != nil {
+		cfg.Notify.Notifiers = append(cfg.Notify.Notifiers, temp.Notify.Notifiers...)
+		if temp.Notify.BatchSize != nil {
+			cfg.Notify.BatchSize = temp.Notify.BatchSize
+		}
+		if temp.Notify.Interval != nil {
+			cfg.Notify.Interval = temp.Notify.Interval
+		}
+		if temp.Notify.Retries != nil {
+			cfg.Notify.Retries = temp.Notify.Retries
+		}
+	}
+
+	if temp.Log != nil {
+		if temp.Log.Level != "" {
+			cfg.Log.Level = temp.Log.Level
+		}
+		if temp.Log.Components != nil {
+			cfg.Log.Components = temp.Log.Components
+		}
+		if temp.Log.File != "" {
+			cfg.Log.File = temp.Log.File
+		}
+		if temp.Log.MaxSize != nil {
+			cfg.Log.MaxSize = temp.Log.MaxSize
+		}
+		if temp.Log.MaxBackups != nil {
+			cfg.Log.MaxBackups = temp.Log.MaxBackups
+		}
+		if temp.Log.Access != nil {
+			cfg.Log.Access = temp.Log.Access
+		}
+	}
+
+	if temp.Tracing != nil {
+		if temp.Tracing.Endpoint != "" {
+			cfg.Tracing.Endpoint = temp.Tracing.Endpoint
+		}
+		if temp.Tracing.Headers != nil {
+			cfg.Tracing.Headers = temp.Tracing.Headers
+		}
+		if temp.Tracing.SampleRatio != nil {
+			cfg.Tracing.SampleRatio = temp.Tracing.SampleRatio
+		}
+	}
+
+	if temp.Progress != nil {
+		if temp.Progress.Enabled != nil {
+			cfg.Progress.Enabled = temp.Progress.Enabled
+		}
+		if temp.Progress.Interval != nil {
+			cfg.Progress.Interval = temp.Progress.Interval
+		}
+	}
+
+	if temp.Pii != nil {
+		if temp.Pii.Enabled != nil {
+			cfg.Pii.Enabled = temp.Pii.Enabled
+		}
+		if len(temp.Pii.Countries) > 0 {
+			cfg.Pii.Countries = temp.Pii.Countries
+		}
+	}
+
+	if temp.Har != nil {
+		if temp.Har.File != "" {
+			cfg.Har.File = temp.Har.File
+		}
+		if temp.Har.Seed != nil {
+			cfg.Har.Seed = temp.Har.Seed
+		}
+		if temp.Har.Cookies != nil {
+			cfg.Har.Cookies = temp.Har.Cookies
+		}
+	}
+
+	if temp.Warc != nil {
+		if temp.Warc.Dir != "" {
+			cfg.Warc.Dir = temp.Warc.Dir
+		}
+		if temp.Warc.MaxSize != nil {
+			cfg.Warc.MaxSize = temp.Warc.MaxSize
+		}
+	}
+
+	if temp.Git != nil {
+		if temp.Git.Enabled != nil {
+			cfg.Git.Enabled = temp.Git.Enabled
+		}
+		if temp.Git.MaxObjects != nil {
+			cfg.Git.MaxObjects = temp.Git.MaxObjects
+		}
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,270 @@
+package crawler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// archives whose decompressed size stays under ratioGrace are never
+// rejected for their compression ratio, small text files compress well.
+const ratioGrace = 1024 * 1024
+
+var errArchiveLimit = errors.New("archive limit exceeded")
+
+type archiveLimits struct {
+	maxTotalSize int64
+	maxRatio     int64
+	maxEntries   int
+	maxDepth     int
+}
+
+func newArchiveLimits(a *config.Archives) archiveLimits {
+	al := archiveLimits{}
+	if a == nil {
+		return al
+	}
+	if a.MaxTotalSize != nil {
+		al.maxTotalSize = *a.MaxTotalSize
+	}
+	if a.MaxRatio != nil {
+		al.maxRatio = int64(*a.MaxRatio)
+	}
+	if a.MaxEntries != nil {
+		al.maxEntries = *a.MaxEntries
+	}
+	if a.MaxDepth != nil {
+		al.maxDepth = *a.MaxDepth
+	}
+	return al
+}
+
+// bombGuard keeps track of the bytes going in and out of an archive,
+// decompression stops as soon as one of the limits is exceeded.
+type bombGuard struct {
+	limits       archiveLimits
+	compressed   int64
+	decompressed int64
+	entries      int
+}
+
+func (g *bombGuard) check() error {
+	if g.limits.maxTotalSize > 0 && g.decompressed > g.limits.maxTotalSize {
+		return fmt.Errorf("%w: more than %d decompressed bytes", errArchiveLimit, g.limits.maxTotalSize)
+	}
+	if g.limits.maxRatio > 0 && g.decompressed > ratioGrace && g.decompressed > g.limits.maxRatio*max(g.compressed, 1) {
+		return fmt.Errorf("%w: compression ratio above %d", errArchiveLimit, g.limits.maxRatio)
+	}
+	return nil
+}
+
+func (g *bombGuard) entry() error {
+	g.entries++
+	if g.limits.maxEntries > 0 && g.entries > g.limits.maxEntries {
+		return fmt.Errorf("%w: more than %d entries", errArchiveLimit, g.limits.maxEntries)
+	}
+	return nil
+}
+
+// countingReader counts the compressed bytes read from the archive.
+type countingReader struct {
+	r     io.Reader
+	guard *bombGuard
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.guard.compressed += int64(n)
+	return n, err
+}
+
+// guardedReader counts the decompressed bytes of an archive member.
+type guardedReader struct {
+	r     io.Reader
+	guard *bombGuard
+}
+
+func (g *guardedReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.guard.decompressed += int64(n)
+	if lerr := g.guard.check(); lerr != nil {
+		return n, lerr
+	}
+	return n, err
+}
+
+// archiveProcessor scans the files held by gzip, bzip2, zip and tar
+// archives. Each file is handled by the processor of its own type and its
+// findings are attributed to archive-url!inner/path.
+type archiveProcessor struct{}
+
+func (p *archiveProcessor) process(body io.Reader, sink *scanSink) ([]ref, error) {
+	if maxDepth := sink.registry.archive.maxDepth; maxDepth > 0 && sink.depth >= maxDepth {
+		return nil, nil // nested too deep, leave it alone
+	}
+	guard := sink.guard
+	if guard == nil {
+		guard = &bombGuard{limits: sink.registry.archive}
+		body = &countingReader{r: body, guard: guard}
+	}
+	return nil, walkArchive(body, path.Base(sink.name), guard, sink)
+}
+
+// walkArchive detects the archive format from its magic bytes and hands every
+// member to processEntry.
+func walkArchive(body io.Reader, name string, guard *bombGuard, sink *scanSink) error {
+	br := bufio.NewReaderSize(body, 512)
+	magic, _ := br.Peek(512)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return walkZip(br, guard, sink)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		inner := gz.Name
+		if len(inner) == 0 {
+			inner = trimArchiveExt(name)
+		}
+		return walkCompressed(&guardedReader{r: gz, guard: guard}, inner, guard, sink)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return walkCompressed(&guardedReader{r: bzip2.NewReader(br), guard: guard}, trimArchiveExt(name), guard, sink)
+	case isTar(magic):
+		return walkTar(br, guard, sink)
+	}
+	return fmt.Errorf("unknown archive format")
+}
+
+// walkCompressed handles the single stream of gzip and bzip2 files, which is
+// usually a tarball.
+func walkCompressed(r io.Reader, name string, guard *bombGuard, sink *scanSink) error {
+	br := bufio.NewReaderSize(r, 512)
+	magic, _ := br.Peek(512)
+	if isTar(magic) {
+		return walkTar(br, guard, sink)
+	}
+	return processEntry(br, name, guard, sink)
+}
+
+func walkTar(r io.Reader, guard *bombGuard, sink *scanSink) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := processEntry(&guardedReader{r: tr, guard: guard}, hdr.Name, guard, sink); err != nil {
+			return err
+		}
+	}
+}
+
+// walkZip buffers the archive, zip files can only be read from their central
+// directory at the end. The buffer is bounded by the body size limit.
+func walkZip(r io.Reader, guard *bombGuard, sink *scanSink) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		// reject obvious bombs before inflating anything
+		declared := guard.decompressed + int64(f.UncompressedSize64)
+		if guard.limits.maxTotalSize > 0 && declared > guard.limits.maxTotalSize {
+			return fmt.Errorf("%w: %s declares %d bytes", errArchiveLimit, f.Name, f.UncompressedSize64)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = processEntry(&guardedReader{r: rc, guard: guard}, f.Name, guard, sink)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processEntry scans a member of the archive with the processor of its type,
+// nested archives included.
+func processEntry(r io.Reader, name string, guard *bombGuard, sink *scanSink) error {
+	if err := guard.entry(); err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, peekSize)
+	peek, _ := br.Peek(peekSize)
+
+	_, proc := sink.registry.lookup(contentTypeOf(name, peek), peek, name)
+	if proc == nil {
+		_, err := io.Copy(io.Discard, br)
+		return err
+	}
+	entry := sink.entry(name)
+	entry.guard = guard
+	defer entry.close()
+	if _, err := proc.process(br, entry); err != nil {
+		return err
+	}
+	_, err := io.Copy(io.Discard, br)
+	return err
+}
+
+// contentTypeOf guesses the type of an archive member from its name, falling
+// back to content sniffing.
+func contentTypeOf(name string, peek []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".zip", ".jar", ".war", ".apk":
+		return "application/zip"
+	case ".gz", ".tgz":
+		return "application/gzip"
+	case ".bz2", ".tbz2":
+		return "application/x-bzip2"
+	case ".tar":
+		return "application/x-tar"
+	case ".html", ".htm":
+		return "text/html"
+	case ".xml":
+		return "application/xml"
+	}
+	return sniffContentType(peek)
+}
+
+func trimArchiveExt(name string) string {
+	switch ext := strings.ToLower(path.Ext(name)); ext {
+	case ".tgz", ".tbz2":
+		return strings.TrimSuffix(name, path.Ext(name)) + ".tar"
+	case ".gz", ".bz2":
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return name
+}
+
+func isTar(magic []byte) bool {
+	return len(magic) >= 262 && string(magic[257:262]) == "ustar"
+}
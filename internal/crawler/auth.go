@@ -0,0 +1,611 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"golang.org/x/net/html"
+)
+
+const defaultLogoutPattern = `(?i)/(log|sign)[-_]?(out|off)\b`
+
+type authenticator interface {
+	// login (re)establishes the session, it is called once before crawling
+	// and every time the session is detected as logged out.
+	login(ctx context.Context) error
+	apply(req *http.Request)
+	expired() bool
+}
+
+// challenger is implemented by authenticators that need a server challenge
+// before being able to authenticate a request.
+type challenger interface {
+	challenge(resp *http.Response) bool
+}
+
+type authSession struct {
+	mu         sync.Mutex
+	auth       authenticator
+	domains    []string
+	loggedOut  *loggedOutDetector
+	generation int64
+}
+
+type loggedOutDetector struct {
+	status  []int
+	pattern *regexp.Regexp
+	url     *regexp.Regexp
+}
+
+// newAuthSession returns the session described by the config, the login
+// requests carry the headers of the crawl.
+func newAuthSession(a *config.Auth, targets []string, headers *headerSet) (*authSession, error) {
+	if a == nil || len(a.Type) == 0 {
+		return nil, nil
+	}
+	var (
+		auth authenticator
+		err  error
+	)
+	switch strings.ToLower(a.Type) {
+	case "form":
+		auth, err = newFormAuth(a, headers)
+	case "basic":
+		auth, err = newBasicAuth(a)
+	case "digest":
+		auth, err = newDigestAuth(a)
+	case "bearer":
+		auth, err = newBearerAuth(a)
+	case "oauth2":
+		auth, err = newOAuth2Auth(a, headers)
+	default:
+		err = fmt.Errorf("unsupported auth type %q", a.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	detector, err := newLoggedOutDetector(a)
+	if err != nil {
+		return nil, err
+	}
+	domains := a.Domains
+	if len(domains) == 0 {
+		domains = targets
+	}
+	return &authSession{auth: auth, domains: domains, loggedOut: detector}, nil
+}
+
+func newLoggedOutDetector(a *config.Auth) (*loggedOutDetector, error) {
+	d := &loggedOutDetector{status: []int{http.StatusUnauthorized}}
+	lo := a.LoggedOut
+	if lo == nil {
+		lo = &config.LoggedOut{}
+		if len(a.LoginUrl) > 0 {
+			lo.Url = "^" + regexp.QuoteMeta(a.LoginUrl)
+		}
+	}
+	if len(lo.Status) > 0 {
+		d.status = lo.Status
+	}
+	var err error
+	if len(lo.Pattern) > 0 {
+		if d.pattern, err = regexp.Compile(lo.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid logged out pattern: %w", err)
+		}
+	}
+	if len(lo.Url) > 0 {
+		if d.url, err = regexp.Compile(lo.Url); err != nil {
+			return nil, fmt.Errorf("invalid logged out url: %w", err)
+		}
+	}
+	return d, nil
+}
+
+func (d *loggedOutDetector) match(resp *http.Response, body []byte) bool {
+	if slices.Contains(d.status, resp.StatusCode) {
+		return true
+	}
+	if d.url != nil && resp.Request != nil && d.url.MatchString(resp.Request.URL.String()) {
+		return true
+	}
+	return d.pattern != nil && d.pattern.Match(body)
+}
+
+func (s *authSession) login(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.auth.login(ctx); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.generation, 1)
+	return nil
+}
+
+// session returns the current login generation, used to avoid logging in
+// again for every worker that noticed the same expired session.
+func (s *authSession) session() int64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&s.generation)
+}
+
+func (s *authSession) apply(ctx context.Context, req *http.Request) error {
+	if s == nil || !s.inScope(req.URL) {
+		return nil
+	}
+	if s.auth.expired() {
+		if err := s.relogin(ctx, s.session()); err != nil {
+			return err
+		}
+	}
+	s.auth.apply(req)
+	return nil
+}
+
+func (s *authSession) inScope(u *url.URL) bool {
+	hostname := u.Hostname()
+	for _, pattern := range s.domains {
+		if matchGlob(pattern, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry reports whether the request should be sent again, either because the
+// server sent an authentication challenge or because the session expired.
+func (s *authSession) retry(ctx context.Context, resp *http.Response, body []byte, generation int64) (bool, error) {
+	if s == nil || resp.Request == nil || !s.inScope(resp.Request.URL) {
+		return false, nil
+	}
+	if ch, ok := s.auth.(challenger); ok && ch.challenge(resp) {
+		return true, nil
+	}
+	if !s.loggedOut.match(resp, body) {
+		return false, nil
+	}
+	if err := s.relogin(ctx, generation); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *authSession) relogin(ctx context.Context, generation int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session() != generation {
+		return nil // another worker already logged in again
+	}
+	if err := s.auth.login(ctx); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.generation, 1)
+	return nil
+}
+
+func credentialFromEnv(name, what string) (string, error) {
+	if len(name) == 0 {
+		return "", fmt.Errorf("auth %s environment variable is not set in the config", what)
+	}
+	v := os.Getenv(name)
+	if len(v) == 0 {
+		return "", fmt.Errorf("environment variable %s holding the auth %s is empty", name, what)
+	}
+	return v, nil
+}
+
+func credentialsFromEnv(a *config.Auth) (string, string, error) {
+	username, err := credentialFromEnv(a.UsernameEnv, "username")
+	if err != nil {
+		return "", "", err
+	}
+	password, err := credentialFromEnv(a.PasswordEnv, "password")
+	if err != nil {
+		return "", "", err
+	}
+	return username, password, nil
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func newBasicAuth(a *config.Auth) (*basicAuth, error) {
+	username, password, err := credentialsFromEnv(a)
+	if err != nil {
+		return nil, err
+	}
+	return &basicAuth{username: username, password: password}, nil
+}
+
+func (b *basicAuth) login(ctx context.Context) error { return nil }
+func (b *basicAuth) expired() bool                   { return false }
+func (b *basicAuth) apply(req *http.Request)         { req.SetBasicAuth(b.username, b.password) }
+
+type bearerAuth struct {
+	token string
+}
+
+func newBearerAuth(a *config.Auth) (*bearerAuth, error) {
+	token, err := credentialFromEnv(a.TokenEnv, "token")
+	if err != nil {
+		return nil, err
+	}
+	return &bearerAuth{token: token}, nil
+}
+
+func (b *bearerAuth) login(ctx context.Context) error { return nil }
+func (b *bearerAuth) expired() bool                   { return false }
+func (b *bearerAuth) apply(req *http.Request)         { req.Header.Set("Authorization", "Bearer "+b.token) }
+
+type digestAuth struct {
+	mu       sync.Mutex
+	username string
+	password string
+	params   map[string]string // last challenge received
+	nc       int
+}
+
+func newDigestAuth(a *config.Auth) (*digestAuth, error) {
+	username, password, err := credentialsFromEnv(a)
+	if err != nil {
+		return nil, err
+	}
+	return &digestAuth{username: username, password: password}, nil
+}
+
+func (d *digestAuth) login(ctx context.Context) error { return nil }
+func (d *digestAuth) expired() bool                   { return false }
+
+func (d *digestAuth) challenge(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	for _, h := range resp.Header.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(h, " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		d.mu.Lock()
+		d.params = parseAuthParams(rest)
+		d.nc = 0
+		d.mu.Unlock()
+		return true
+	}
+	return false
+}
+
+func (d *digestAuth) apply(req *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.params == nil {
+		return // wait for the first challenge
+	}
+	var h func() hash.Hash
+	algorithm := d.params["algorithm"]
+	switch strings.ToUpper(algorithm) {
+	case "SHA-256":
+		h = sha256.New
+	default:
+		h = md5.New
+	}
+	digest := func(parts ...string) string {
+		hs := h()
+		io.WriteString(hs, strings.Join(parts, ":"))
+		return hex.EncodeToString(hs.Sum(nil))
+	}
+
+	realm, nonce, opaque := d.params["realm"], d.params["nonce"], d.params["opaque"]
+	uri := req.URL.RequestURI()
+	ha1 := digest(d.username, realm, d.password)
+	ha2 := digest(req.Method, uri)
+
+	var response string
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, d.username),
+		fmt.Sprintf(`realm="%s"`, realm),
+		fmt.Sprintf(`nonce="%s"`, nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+	}
+	if qop := d.params["qop"]; len(qop) > 0 {
+		d.nc++
+		nc := fmt.Sprintf("%08x", d.nc)
+		cnonce := randomHex(8)
+		response = digest(ha1, nonce, nc, cnonce, "auth", ha2)
+		fields = append(fields, "qop=auth", "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	} else {
+		response = digest(ha1, nonce, ha2)
+	}
+	fields = append(fields, fmt.Sprintf(`response="%s"`, response))
+	if len(opaque) > 0 {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	if len(algorithm) > 0 {
+		fields = append(fields, "algorithm="+algorithm)
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(fields, ", "))
+}
+
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, s = rest[1:end+1], rest[end+2:]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return params
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type formAuth struct {
+	loginUrl string
+	fields   url.Values
+	headers  *headerSet
+}
+
+func newFormAuth(a *config.Auth, headers *headerSet) (*formAuth, error) {
+	if len(a.LoginUrl) == 0 {
+		return nil, errors.New("form auth requires a login url")
+	}
+	username, password, err := credentialsFromEnv(a)
+	if err != nil {
+		return nil, err
+	}
+	fields := url.Values{}
+	for k, v := range a.Fields {
+		fields.Set(k, v)
+	}
+	usernameField, passwordField := a.UsernameField, a.PasswordField
+	if len(usernameField) == 0 {
+		usernameField = "username"
+	}
+	if len(passwordField) == 0 {
+		passwordField = "password"
+	}
+	fields.Set(usernameField, username)
+	fields.Set(passwordField, password)
+	return &formAuth{loginUrl: a.LoginUrl, fields: fields, headers: headers}, nil
+}
+
+func (f *formAuth) expired() bool           { return false }
+func (f *formAuth) apply(req *http.Request) {} // the session lives in the cookie jar
+
+// login fetches the login page first so that hidden inputs such as csrf
+// tokens and the session cookie are picked up, then submits the form.
+func (f *formAuth) login(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", f.loginUrl, nil)
+	if err != nil {
+		return err
+	}
+	f.headers.apply(req)
+	resp, err := netClient.Do(req)
+	if err != nil {
+		return err
+	}
+	page, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	action, hidden, _ := parseLoginForm(resp.Request.URL, page)
+	if len(action) == 0 {
+		action = f.loginUrl
+	}
+	fields := url.Values{}
+	for k, v := range hidden {
+		fields[k] = v
+	}
+	for k, v := range f.fields {
+		fields[k] = v
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "POST", action, strings.NewReader(fields.Encode()))
+	if err != nil {
+		return err
+	}
+	f.headers.apply(req)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err = netClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("form login failed: %v", resp.Status)
+	}
+	page, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// rejected credentials usually get the login form back, either as an
+	// error page or through a redirect to the login page
+	if _, _, ok := parseLoginForm(resp.Request.URL, page); ok {
+		return fmt.Errorf("form login failed: %s still shows a login form", resp.Request.URL)
+	}
+	return nil
+}
+
+// parseLoginForm returns the action and hidden inputs of the first form
+// containing a password input, ok is false when the page has none.
+func parseLoginForm(base *url.URL, page []byte) (action string, hidden url.Values, ok bool) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	var (
+		inForm   bool
+		password bool
+	)
+	hidden = url.Values{}
+	for {
+		tok := tokenizer.Next()
+		switch tok {
+		case html.ErrorToken:
+			return "", url.Values{}, false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "form":
+				inForm, password, action, hidden = true, false, "", url.Values{}
+				if v := attrValue(token, "action"); len(v) > 0 {
+					if u, err := base.Parse(v); err == nil {
+						action = u.String()
+					}
+				}
+			case "input":
+				if !inForm {
+					continue
+				}
+				switch strings.ToLower(attrValue(token, "type")) {
+				case "password":
+					password = true
+				case "hidden":
+					if name := attrValue(token, "name"); len(name) > 0 {
+						hidden.Set(name, attrValue(token, "value"))
+					}
+				}
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().Data == "form" {
+				if password {
+					return action, hidden, true
+				}
+				inForm = false
+			}
+		}
+	}
+}
+
+func attrValue(token html.Token, key string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+type oauth2Auth struct {
+	mu           sync.RWMutex
+	tokenUrl     string
+	clientId     string
+	clientSecret string
+	scopes       []string
+	token        string
+	expiry       time.Time
+	headers      *headerSet
+}
+
+func newOAuth2Auth(a *config.Auth, headers *headerSet) (*oauth2Auth, error) {
+	if len(a.TokenUrl) == 0 {
+		return nil, errors.New("oauth2 auth requires a token url")
+	}
+	clientId, err := credentialFromEnv(a.ClientIdEnv, "client id")
+	if err != nil {
+		return nil, err
+	}
+	clientSecret, err := credentialFromEnv(a.ClientSecretEnv, "client secret")
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2Auth{tokenUrl: a.TokenUrl, clientId: clientId, clientSecret: clientSecret, scopes: a.Scopes, headers: headers}, nil
+}
+
+func (o *oauth2Auth) login(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.scopes) > 0 {
+		form.Set("scope", strings.Join(o.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", o.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	o.headers.apply(req)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.clientId), url.QueryEscape(o.clientSecret))
+	resp, err := netClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth2 token request failed: %v", resp.Status)
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return fmt.Errorf("invalid oauth2 token response: %w", err)
+	}
+	if len(tr.AccessToken) == 0 {
+		return errors.New("oauth2 token response has no access token")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.token = tr.AccessToken
+	o.expiry = time.Time{}
+	if tr.ExpiresIn > 0 {
+		// refresh a little early so in flight requests don't use a stale
+		// token, short lived tokens still get half of their lifetime
+		lifetime := time.Duration(tr.ExpiresIn) * time.Second
+		o.expiry = time.Now().Add(lifetime - min(10*time.Second, lifetime/2))
+	}
+	return nil
+}
+
+func (o *oauth2Auth) expired() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return !o.expiry.IsZero() && time.Now().After(o.expiry)
+}
+
+func (o *oauth2Auth) apply(req *http.Request) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	req.Header.Set("Authorization", "Bearer "+o.token)
+}
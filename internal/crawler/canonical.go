@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// matrixSession matches session ids carried as path parameters, such as
+// /cart;jsessionid=0123abcd
+var matrixSession = regexp.MustCompile(`(?i);(jsessionid|phpsessid|sid)=[^/?#]*`)
+
+type canonicalizer struct {
+	stripParams       []string
+	keepTrailingSlash bool
+}
+
+func newCanonicalizer(c *config.Canonical) *canonicalizer {
+	cn := &canonicalizer{}
+	if c == nil {
+		return cn
+	}
+	for _, p := range c.StripParams {
+		cn.stripParams = append(cn.stripParams, strings.ToLower(p))
+	}
+	cn.keepTrailingSlash = c.KeepTrailingSlash != nil && *c.KeepTrailingSlash
+	return cn
+}
+
+// canonicalize returns the form of u used to tell whether two urls point to
+// the same resource. Fragments, default ports, tracking and session
+// parameters are removed, the query is sorted and percent-encoding is
+// normalized.
+func (cn *canonicalizer) canonicalize(u string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return "", err
+	}
+	parsed.Fragment, parsed.RawFragment = "", ""
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // ipv6
+	}
+	if port := parsed.Port(); len(port) > 0 && port != urlPort(&url.URL{Scheme: parsed.Scheme}) {
+		host += ":" + port
+	}
+	parsed.Host = host
+
+	p := normalizeEscapes(matrixSession.ReplaceAllString(parsed.EscapedPath(), ""))
+	if len(p) == 0 {
+		p = "/"
+	}
+	if !cn.keepTrailingSlash && len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	path, err := url.PathUnescape(p)
+	if err != nil {
+		return "", err
+	}
+	parsed.Path, parsed.RawPath = path, p
+
+	parsed.RawQuery = cn.canonicalQuery(parsed.RawQuery)
+	parsed.ForceQuery = false
+	return parsed.String(), nil
+}
+
+func (cn *canonicalizer) canonicalQuery(raw string) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		if cn.stripped(k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		values := q[k]
+		sort.Strings(values)
+		for _, v := range values {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(k))
+			sb.WriteByte('=')
+			sb.WriteString(url.QueryEscape(v))
+		}
+	}
+	return sb.String()
+}
+
+func (cn *canonicalizer) stripped(param string) bool {
+	param = strings.ToLower(param)
+	for _, pattern := range cn.stripParams {
+		if matchGlob(pattern, param) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters and
+// uppercases the hex digits of the remaining escapes, as described in
+// RFC 3986 section 6.2.2.
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		b := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(b) {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteByte('%')
+			sb.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return sb.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"golang.org/x/net/publicsuffix"
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// newCookieJar creates the jar shared by every request, seeded with the
+// cookies from the config, the cookies file and the HAR file. Cookies set by responses are
+// kept in the jar for the rest of the crawl.
+func newCookieJar(c config.Config) (*cookiejar.Jar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	for _, ck := range c.Cookies {
+		setCookie(jar, ck.Domain, &http.Cookie{Name: ck.Name, Value: ck.Value, Path: ck.Path})
+	}
+	if len(c.CookieFile) > 0 {
+		if err := loadCookieFile(jar, c.CookieFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.Har != nil && len(c.Har.File) > 0 && c.Har.Cookies != nil && *c.Har.Cookies {
+		if err := loadHarCookies(jar, c.Har.File); err != nil {
+			return nil, err
+		}
+	}
+	return jar, nil
+}
+
+func setCookie(jar *cookiejar.Jar, domain string, ck *http.Cookie) {
+	scheme := "http"
+	if ck.Secure {
+		scheme = "https"
+	}
+	if len(ck.Path) == 0 {
+		ck.Path = "/"
+	}
+	u := &url.URL{Scheme: scheme, Host: strings.TrimPrefix(domain, "."), Path: "/"}
+	jar.SetCookies(u, []*http.Cookie{ck})
+}
+
+// loadCookieFile reads cookies in the netscape cookies.txt format, as
+// exported by curl and most browser extensions.
+func loadCookieFile(jar *cookiejar.Jar, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		txt := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(txt, httpOnlyPrefix)
+		if httpOnly {
+			txt = strings.TrimPrefix(txt, httpOnlyPrefix)
+		}
+		if len(txt) == 0 || strings.HasPrefix(txt, "#") {
+			continue
+		}
+		fields := strings.Split(txt, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("malformed cookie at %s:%d", src, line)
+		}
+		domain, includeSubdomains, path, secure, expiry, name, value :=
+			fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6]
+
+		ck := &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     path,
+			Secure:   strings.EqualFold(secure, "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(includeSubdomains, "TRUE") {
+			ck.Domain = strings.TrimPrefix(domain, ".")
+		}
+		if ts, err := strconv.ParseInt(expiry, 10, 64); err == nil && ts > 0 {
+			ck.Expires = time.Unix(ts, 0)
+		}
+		setCookie(jar, domain, ck)
+	}
+	return scanner.Err()
+}
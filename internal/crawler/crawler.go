@@ -1,7 +1,9 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -9,50 +11,196 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/got-many-wheels/spoderman/internal/config"
 	"github.com/got-many-wheels/spoderman/internal/logger"
+	"github.com/got-many-wheels/spoderman/internal/metrics"
+	"github.com/got-many-wheels/spoderman/internal/notify"
+	"github.com/got-many-wheels/spoderman/internal/redact"
+	"github.com/got-many-wheels/spoderman/internal/report"
+	"github.com/got-many-wheels/spoderman/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// componentLogs are the loggers of the parts of the crawler whose level can
+// be set apart, see logger.Components.
+type componentLogs struct {
+	fetch, parse, scan, queue, access *logger.Logger
+}
+
 type Crawler struct {
-	urls    []string
-	logger  *logger.Logger
-	filters *chainedFilters
-	config  config.Config
-	wg      sync.WaitGroup
-	jq      *jobQueue
+	urls       []string
+	logger     *logger.Logger
+	logs       componentLogs
+	filters    *chainedFilters
+	headers    *headerSet
+	auth       *authSession
+	canon      *canonicalizer
+	traps      *trapDetector
+	dedup      *dedupIndex
+	rules      *ruleSet
+	limits     *bodyLimits
+	processors *processorRegistry
+	prober     *prober
+	git        *gitDumper
+	graph      *linkGraph
+	store      *store.Store
+	redactor   *redact.Redactor
+	warc       *warcWriter
+	stats      *crawlStats
+	metrics    *metrics.Metrics
+	notifiers  *notify.Notifiers
+	tracer     trace.Tracer
+	flushSpans func(context.Context) error
+	config     config.Config
+	wg         sync.WaitGroup
+	jq         *jobQueue
 }
 
-func New(logger *logger.Logger, urls []string, c config.Config) *Crawler {
+func New(logger *logger.Logger, urls []string, c config.Config) (*Crawler, error) {
 	var f []urlFilter
 	if len(c.AllowedDomains) > 0 {
 		f = append(f, &allowedFilter{allowed: c.AllowedDomains})
 	}
 	f = append(f, &disallowedFilter{disallowed: c.DisallowedDomains})
+	if len(c.Scope) > 0 {
+		sf, err := newScopeFilter(logger.Component("queue"), c.Scope)
+		if err != nil {
+			return nil, err
+		}
+		f = append(f, sf)
+	}
 	filters := &chainedFilters{filters: f}
 
-	return &Crawler{
-		urls:    urls,
-		logger:  logger,
-		config:  c,
-		jq:      newJobQueue(*c.Interval),
-		filters: filters,
+	pii, err := piiRules(c.Pii)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := newRuleSet(append(pii, c.Rules...))
+	if err != nil {
+		return nil, err
+	}
+	prober, err := newProber(c.Probe)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := newLinkGraph(c.Graph)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := redact.New(c.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	warc, err := newWarcWriter(c.Warc, c.Redaction == nil || c.Redaction.Mode != redact.ModeNone)
+	if err != nil {
+		return nil, err
+	}
+	var st *store.Store
+	if len(c.Store) > 0 {
+		if st, err = store.Open(c.Store); err != nil {
+			return nil, err
+		}
+	}
+	tracer, flushSpans, err := newTracer(c.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	processors, err := newProcessorRegistry(c.Processors, c.ScanBinary != nil && *c.ScanBinary, c.Archives)
+	if err != nil {
+		return nil, err
+	}
+	notifiers, err := notify.New(c.Notify, logger.Component("notify"))
+	if err != nil {
+		return nil, err
+	}
+
+	crawler := &Crawler{
+		urls:   urls,
+		logger: logger,
+		logs: componentLogs{
+			fetch:  logger.Component("fetch"),
+			parse:  logger.Component("parse"),
+			scan:   logger.Component("scan"),
+			queue:  logger.Component("queue"),
+			access: logger.Component("access"),
+		},
+		config:     c,
+		jq:         newJobQueue(*c.Interval),
+		filters:    filters,
+		headers:    newHeaderSet(c),
+		canon:      newCanonicalizer(c.Canonical),
+		traps:      newTrapDetector(c.Traps),
+		dedup:      newDedupIndex(c.Dedup),
+		rules:      rules,
+		limits:     newBodyLimits(c),
+		processors: processors,
+		prober:     prober,
+		git:        newGitDumper(c.Git),
+		graph:      graph,
+		store:      st,
+		redactor:   redactor,
+		warc:       warc,
+		tracer:     tracer,
+		flushSpans: flushSpans,
+		notifiers:  notifiers,
+	}
+	if notifiers != nil {
+		crawler.jq.found = crawler.notify
 	}
+	if len(c.MinSeverity) > 0 {
+		if crawler.jq.minRank = slices.Index(config.Severities, c.MinSeverity); crawler.jq.minRank < 0 {
+			return nil, fmt.Errorf("unknown minimum severity %q, severities are %s", c.MinSeverity, strings.Join(config.Severities, ", "))
+		}
+	}
+	return crawler, nil
 }
 
 func (c *Crawler) Do() error {
-	newNetClient()
-	if len(c.urls) == 0 {
+	if len(c.urls) == 0 && (c.config.Har == nil || len(c.config.Har.File) == 0) {
 		return errors.New("Please provide at least 1 url to crawl to")
 	}
+	started := time.Now()
+	c.stats = newCrawlStats()
+	if _, err := newNetClient(c.config); err != nil {
+		return err
+	}
+	if err := c.setupAuth(); err != nil {
+		return err
+	}
+	if len(c.config.MetricsAddr) > 0 {
+		m, err := metrics.Serve(c.config.MetricsAddr)
+		if err != nil {
+			return err
+		}
+		c.metrics, c.jq.metrics = m, m
+		defer m.Close()
+	}
+	defer c.flushSpans(context.Background())
+	defer c.notifiers.Close()
+	defer c.store.Close()
+	defer c.warc.close()
+	if err := c.store.BeginRun(started, c.urls); err != nil {
+		return err
+	}
+	harJobs, harSecrets, err := c.importHar(make([]byte, 0, scanChunkSize+scanOverlap))
+	if err != nil {
+		return fmt.Errorf("failed to import har: %w", err)
+	}
 	var numWorkerCreated int64
 	pool := &sync.Pool{
 		New: func() any {
 			atomic.AddInt64(&numWorkerCreated, 1)
-			buf := make([]byte, 0, 1024*32)
+			buf := make([]byte, 0, scanChunkSize+scanOverlap)
 			return buf
 		},
 	}
@@ -71,9 +219,15 @@ func (c *Crawler) Do() error {
 			c.logger.Error().Msg(err.Error())
 			continue
 		}
+		if key, err := c.canon.canonicalize(initialUrl); err == nil {
+			c.jq.isVisited(key)
+		}
 		initialJobs = append(initialJobs, job{url: initialUrl, depth: 1})
+		initialJobs = append(initialJobs, c.probeJobs(initialUrl, 1)...)
 	}
-	c.jq.enqueue(initialJobs, []Secret{})
+	c.jq.enqueue(append(initialJobs, harJobs...), harSecrets)
+	progress := newProgress(c.config.Progress, c.stats, c.jq, c.logger)
+	progress.start()
 
 	go func() {
 		<-sigChan
@@ -86,34 +240,120 @@ func (c *Crawler) Do() error {
 	c.jq.clear()
 	c.jq.stopTickerChan() // close ticker channel so that the program can exit
 	c.wg.Wait()
-	c.jq.outputResults(c.config.Output)
-
+	c.output(started, c.urls)
 	c.logger.Debug().Msg(fmt.Sprintf("%d worker instance created", int(numWorkerCreated)))
 	c.logger.Info().Msg(fmt.Sprintf("%d links crawled successfully", c.jq.crawled))
+	progress.finish()
 	return nil
 }
 
-func (c *Crawler) req(url string, buf *[]byte, ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// output writes the results of the run to the configured outputs, targets
+// are what the run was started from.
+func (c *Crawler) output(started time.Time, targets []string) {
+	files, err := c.jq.outputResults(c.config.Output, c.redact)
 	if err != nil {
-		return err
+		c.logger.Error().Msg(err.Error())
+	}
+	if err := c.dedup.outputClusters(c.config.Output); err != nil {
+		c.logger.Error().Msg(err.Error())
+	}
+	if err := c.graph.output(c.config.Output); err != nil {
+		c.logger.Error().Msg(err.Error())
+	}
+
+	if err := c.store.EndRun(time.Now(), c.jq.crawled, storeFindings(c.jq.secrets(), c.redact)); err != nil {
+		c.logger.Error().Msg(err.Error())
+	}
+	if len(c.config.Output) > 0 {
+		run := report.Run{Started: started, Finished: time.Now(), Urls: targets, Crawled: c.jq.crawled, Files: files}
+		if err := report.SaveRun(c.config.Output, run); err != nil {
+			c.logger.Error().Msg(err.Error())
+		}
 	}
-	resp, err := netClient.Do(req)
+}
+
+// setupAuth logs in before any job runs and keeps logout links out of the
+// crawl so the session isn't terminated by the crawler itself.
+func (c *Crawler) setupAuth() error {
+	a := c.config.Auth
+	if a == nil || len(a.Type) == 0 {
+		return nil
+	}
+	var targets []string
+	for _, u := range c.urls {
+		if parsed, err := url.Parse(u); err == nil {
+			targets = append(targets, parsed.Hostname())
+		}
+	}
+	auth, err := newAuthSession(a, targets, c.headers)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http response error: %v", resp.Status)
+	pattern := a.LogoutPattern
+	if len(pattern) == 0 {
+		pattern = defaultLogoutPattern
 	}
-	payload, err := io.ReadAll(resp.Body)
+	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid logout pattern: %w", err)
 	}
-	*buf = append(*buf, payload...)
+	c.filters.filters = append(c.filters.filters, &logoutFilter{pattern: re})
+
+	if err := auth.login(context.Background()); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	c.auth = auth
 	return nil
 }
 
+// req sends the request and returns the response once it is known to be
+// successful, its body is left to be streamed by the caller.
+func (c *Crawler) req(url string, ctx context.Context) (*response, error) {
+	resp, err := c.send(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Close()
+		return nil, fmt.Errorf("http response error: %v", resp.Status)
+	}
+	return resp, nil
+}
+
+// send requests the url with the configured headers and authentication,
+// whatever the response status is.
+func (c *Crawler) send(ctx context.Context, url string) (*response, error) {
+	for attempt := 0; ; attempt++ {
+		session := c.auth.session()
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.headers.apply(req)
+		if err := c.auth.apply(ctx, req); err != nil {
+			return nil, err
+		}
+		httpResp, err := netClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp := newResponse(httpResp, c.limits)
+		if attempt == 0 {
+			retry, err := c.auth.retry(ctx, httpResp, resp.peek, session)
+			if err != nil {
+				resp.Close()
+				return nil, err
+			}
+			if retry {
+				resp.Close()
+				c.metrics.Retry("auth")
+				continue
+			}
+		}
+		return resp, nil
+	}
+}
+
 func (c *Crawler) execute(pool *sync.Pool, ctx context.Context) {
 	defer c.wg.Done()
 	for {
@@ -132,6 +372,7 @@ func (c *Crawler) execute(pool *sync.Pool, ctx context.Context) {
 			buf := pool.Get().([]byte)[:0]
 			defer pool.Put(buf)
 			defer c.jq.jwg.Done()
+			defer c.metrics.WorkerBusy()()
 
 			if *c.config.Depth != 0 && j.depth > *c.config.Depth {
 				return
@@ -140,7 +381,7 @@ func (c *Crawler) execute(pool *sync.Pool, ctx context.Context) {
 			// do check hostname if the new url is within the initial url hostname
 			u, err := url.Parse(j.url)
 			if err != nil {
-				c.logger.Debug().Err(err).Msg(fmt.Sprintf("Error while parsing job url %v\n", j.url))
+				c.logs.fetch.Debug().Err(err).Msg(fmt.Sprintf("Error while parsing job url %v\n", j.url))
 				return
 			}
 
@@ -153,33 +394,179 @@ func (c *Crawler) execute(pool *sync.Pool, ctx context.Context) {
 				}
 			}
 
-			c.logger.Debug().Msg(fmt.Sprintf("Visiting %s", j.url))
+			c.logs.fetch.Debug().Msg(fmt.Sprintf("Visiting %s", j.url))
 
-			err = c.req(j.url, &buf, ctx)
+			jobCtx, span := c.startJobSpan(ctx, j)
+			defer span.End()
+			c.stats.begin(hostname)
+			defer c.stats.end(hostname)
+			requested := time.Now()
+			fetchCtx, fetchSpan := c.tracer.Start(jobCtx, "fetch")
+			resp, err := c.send(fetchCtx, j.url)
 			if err != nil {
+				fetchSpan.RecordError(err)
+				fetchSpan.SetStatus(codes.Error, errorClass(err))
+				fetchSpan.End()
 				// ignore expected canceled error
 				if errors.Is(err, context.Canceled) {
 					return
 				}
-				c.logger.Debug().Err(err).Msg(fmt.Sprintf("Error while requesting to %v\n", j.url))
+				c.stats.fail(errorClass(err))
+				c.metrics.RequestError(hostname, errorClass(err))
+				c.logs.fetch.Debug().Err(err).Msg(fmt.Sprintf("Error while requesting to %v\n", j.url))
+				return
+			}
+			fetchSpan.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			fetchSpan.End()
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			defer resp.Close()
+			c.metrics.Request(hostname, resp.StatusCode, time.Since(requested))
+			page := store.Page{Url: j.url, Status: resp.StatusCode, ContentType: resp.contentType, Depth: j.depth}
+			// the body is streamed once, what the processors read of it is
+			// kept for the archive when one is written.
+			recordID := c.warc.newRecordID()
+			var captured bytes.Buffer
+			var body io.Reader = resp.body
+			if c.warc != nil {
+				body = io.TeeReader(resp.body, &captured)
+			}
+			defer func() {
+				page.Duration = time.Since(requested)
+				c.stats.fetched(resp.size())
+				c.metrics.Bytes(resp.size())
+				c.logs.access.Info().
+					Str("url", j.url).
+					Int("status", page.Status).
+					Int64("bytes", page.Size).
+					Dur("duration", page.Duration).
+					Int("depth", j.depth).
+					Str("parent", j.from).
+					Msg("")
+				if err := c.store.AddPage(page); err != nil {
+					c.logger.Debug().Msg(err.Error())
+				}
+				if c.warc == nil {
+					return
+				}
+				io.Copy(&captured, resp.body)
+				if err := c.warc.writeExchange(resp, captured.Bytes(), resp.body.truncated, recordID); err != nil {
+					c.logger.Debug().Msg(err.Error())
+				}
+			}()
+			if !j.probe {
+				c.graph.visit(j.url, j.depth, resp.StatusCode, resp.contentType)
+			}
+			if resp.StatusCode != http.StatusOK {
+				c.stats.fail(statusClass(resp.StatusCode))
+				c.logs.fetch.Debug().Msg(fmt.Sprintf("Error while requesting to %v: http response error: %v", j.url, resp.Status))
 				return
 			}
-			pNode := newPageNode(j.url, buf)
-			if err := pNode.extractAndExtends(hostname); err != nil {
-				c.logger.Debug().Err(err).Msg(fmt.Sprintf("Error while extracting html content\n"))
+			name, proc := c.processors.lookup(resp.contentType, resp.peek, j.url)
+			if proc == nil && j.probe {
+				proc = &drainProcessor{}
+			} else if proc == nil {
+				c.logs.parse.Debug().Msg(fmt.Sprintf("Skipping %s, %s processor is disabled for %s", j.url, name, resp.contentType))
 				return
 			}
 
-			newJobs := make([]job, 0, len(pNode.foundUrls))
-			for _, url := range pNode.foundUrls {
-				if c.jq.isVisited(url) {
+			pNode := newPageNode(j.url, c.rules)
+			parseStarted := time.Now()
+			_, parseSpan := c.tracer.Start(jobCtx, "parse", trace.WithAttributes(attribute.String("crawl.processor", name)))
+			fp, err := pNode.extractAndExtends(hostname, body, c.processors, proc, buf)
+			parseSpan.SetAttributes(
+				attribute.Int64("crawl.bytes", fp.size),
+				attribute.Bool("crawl.truncated", resp.body.truncated),
+				attribute.Int("crawl.refs", len(pNode.foundRefs)),
+			)
+			if err != nil {
+				parseSpan.RecordError(err)
+			}
+			parseSpan.End()
+			// the rules run while the body is processed, the scan span
+			// accounts for the time they took.
+			_, scanSpan := c.tracer.Start(jobCtx, "scan", trace.WithTimestamp(parseStarted))
+			scanSpan.SetAttributes(attribute.Int("crawl.findings", len(pNode.foundSecrets)))
+			scanSpan.End(trace.WithTimestamp(parseStarted.Add(pNode.scanTime)))
+			span.SetAttributes(attribute.Int64("crawl.bytes", fp.size))
+			if errors.Is(err, errArchiveLimit) {
+				c.logs.parse.Debug().Err(err).Msg(fmt.Sprintf("Stopped inspecting archive %s", j.url))
+			} else if err != nil {
+				c.logs.parse.Debug().Err(err).Msg(fmt.Sprintf("Error while processing %s content of %s\n", name, j.url))
+				return
+			}
+			page.Size, page.Hash = fp.size, hex.EncodeToString(fp.sum[:])
+			if resp.body.truncated {
+				c.logs.parse.Debug().Msg(fmt.Sprintf("Body of %s truncated to %d bytes", j.url, c.limits.limit(resp.contentType)))
+			}
+			if j.probe {
+				if c.prober.softNotFound(ctx, c, j.url, resp, fp) {
+					return
+				}
+				c.logs.scan.Info().Msg(fmt.Sprintf("Exposed path found at %s", j.url))
+				pNode.foundSecrets = append(pNode.foundSecrets, c.rules.secret(hostname, exposedPathRule, j.url, "high", j.url))
+			}
+			for i := range pNode.foundSecrets {
+				pNode.foundSecrets[i].WarcRecord = recordID
+			}
+			// HEAD files are nearly always the same bytes, every exposed
+			// repository is rebuilt and its secrets kept whether or not its
+			// HEAD is a duplicate.
+			if strings.HasSuffix(u.Path, "/.git/HEAD") {
+				if base, ok := c.git.claim(j.url, resp.peek); ok {
+					secrets, err := c.dumpGit(ctx, base, hostname)
+					if err != nil {
+						c.logger.Debug().Err(err).Msg(fmt.Sprintf("Stopped rebuilding %s", base))
+					}
+					c.jq.enqueue(nil, secrets)
+				}
+			}
+			// the fingerprint is only known once the body has been streamed,
+			// so what was found in an exact duplicate on the same host is
+			// discarded instead. A near duplicate may differ by its secrets
+			// and a duplicate on another host makes them findings of that
+			// host, they are kept but its links aren't followed. Probes
+			// aren't deduplicated, the same exposed file on another prefix
+			// is still a finding.
+			if !j.probe {
+				if dup, kind, newHost := c.dedup.seen(j.url, fp); dup {
+					c.logs.queue.Debug().Msg(fmt.Sprintf("Skipping %s, %s duplicate of an already scanned page", j.url, kind))
+					if kind == "near" || newHost {
+						c.jq.enqueue(nil, pNode.foundSecrets)
+					}
+					return
+				}
+			}
+
+			_, enqueueSpan := c.tracer.Start(jobCtx, "enqueue")
+			defer enqueueSpan.End()
+			newJobs := make([]job, 0, len(pNode.foundRefs))
+			for _, ref := range pNode.foundRefs {
+				url := ref.url
+				key, err := c.canon.canonicalize(url)
+				if err != nil {
 					continue
 				}
 				if !c.filters.allow(url) {
 					continue
 				}
+				c.graph.link(j.url, ref, j.depth)
+				if err := c.store.AddEdge(store.Edge{From: j.url, To: ref.url, Tag: ref.tag, Text: ref.text}); err != nil {
+					c.logger.Debug().Msg(err.Error())
+				}
+				if c.jq.isVisited(key) {
+					continue
+				}
+				if reason := c.traps.trapped(key); len(reason) > 0 {
+					c.logs.queue.Debug().Msg(fmt.Sprintf("Dropping %s, possible crawler trap: %s", url, reason))
+					continue
+				}
 				newJobs = append(newJobs, job{url: url, depth: j.depth + 1})
+				newJobs = append(newJobs, c.probeJobs(url, j.depth)...)
 			}
+			for i := range newJobs {
+				newJobs[i].from, newJobs[i].parent = j.url, span.SpanContext()
+			}
+			enqueueSpan.SetAttributes(attribute.Int("crawl.jobs", len(newJobs)))
 			select {
 			case <-ctx.Done():
 				return
@@ -189,3 +576,74 @@ func (c *Crawler) execute(pool *sync.Pool, ctx context.Context) {
 		}()
 	}
 }
+
+// redact returns the secret as it is written to the outputs.
+func (c *Crawler) redact(s Secret) Secret {
+	s.Fingerprint = c.redactor.Fingerprint(s.Value)
+	if !publicRules[s.Key] {
+		s.Value = c.redactor.Redact(s.Value)
+	}
+	return s
+}
+
+// MostSevere returns the severity of the most severe secret found, it is
+// empty when nothing was found.
+func (c *Crawler) MostSevere() string {
+	var severity string
+	for _, s := range c.jq.secrets() {
+		if len(severity) == 0 || rank(config.Severities, s.Severity) < rank(config.Severities, severity) {
+			severity = s.Severity
+		}
+	}
+	return severity
+}
+
+// notify sends a new secret, as written to the outputs, to the notifiers.
+func (c *Crawler) notify(s Secret) {
+	s = c.redact(s)
+	c.notifiers.Notify(notify.Finding{
+		Host:        s.Hostname,
+		Rule:        s.Key,
+		Severity:    s.Severity,
+		Category:    s.Category,
+		Confidence:  s.Confidence,
+		CWE:         s.CWE,
+		Value:       s.Value,
+		Fingerprint: s.Fingerprint,
+		Source:      s.Source,
+	})
+}
+
+func storeFindings(secrets []Secret, redact func(Secret) Secret) []store.Finding {
+	findings := make([]store.Finding, 0, len(secrets))
+	for _, s := range secrets {
+		s = redact(s)
+		findings = append(findings, store.Finding{
+			Host:        s.Hostname,
+			Rule:        s.Key,
+			Severity:    s.Severity,
+			Category:    s.Category,
+			Confidence:  s.Confidence,
+			CWE:         s.CWE,
+			Value:       s.Value,
+			Fingerprint: s.Fingerprint,
+			Source:      s.Source,
+			WarcRecord:  s.WarcRecord,
+		})
+	}
+	return findings
+}
+
+// probeJobs returns the probe jobs of the host of u the first time it is
+// seen, probes share the depth of the page the host was discovered from.
+func (c *Crawler) probeJobs(u string, depth int) []job {
+	var jobs []job
+	for _, probeUrl := range c.prober.discover(u) {
+		key, err := c.canon.canonicalize(probeUrl)
+		if err != nil || c.jq.isVisited(key) || !c.filters.allow(probeUrl) {
+			continue
+		}
+		jobs = append(jobs, job{url: probeUrl, depth: depth, probe: true})
+	}
+	return jobs
+}
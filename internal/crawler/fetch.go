@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// peekSize is buffered from every response to sniff its content type and to
+// look for the logged out pattern.
+const peekSize = 32 * 1024
+
+type bodyLimits struct {
+	def   int64
+	sizes []config.BodySize
+}
+
+func newBodyLimits(c config.Config) *bodyLimits {
+	bl := &bodyLimits{sizes: c.BodySizes}
+	if c.MaxBodySize != nil {
+		bl.def = *c.MaxBodySize
+	}
+	return bl
+}
+
+// limit returns the bytes to read for the content type, 0 means no limit.
+func (bl *bodyLimits) limit(contentType string) int64 {
+	for _, s := range bl.sizes {
+		if matchGlob(s.Type, contentType) {
+			return s.Size
+		}
+	}
+	return bl.def
+}
+
+// response wraps an http response whose body is streamed through body.
+type response struct {
+	*http.Response
+	contentType string
+	body        *limitedReader
+	peek        []byte
+	read        *countedReader // bytes read off the connection
+}
+
+func newResponse(resp *http.Response, limits *bodyLimits) *response {
+	read := &countedReader{r: resp.Body}
+	br := bufio.NewReaderSize(read, peekSize)
+	peek, _ := br.Peek(peekSize)
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || len(contentType) == 0 {
+		contentType = sniffContentType(peek)
+	}
+	contentType = strings.ToLower(contentType)
+	limit := limits.limit(contentType)
+	return &response{
+		Response:    resp,
+		contentType: contentType,
+		body:        &limitedReader{r: br, n: limit, unlimited: limit <= 0},
+		peek:        peek,
+		read:        read,
+	}
+}
+
+// size returns the bytes of the body read so far, whether or not they were
+// processed.
+func (r *response) size() int64 {
+	return r.read.n
+}
+
+func (r *response) Close() error {
+	return r.Response.Body.Close()
+}
+
+// countedReader counts the bytes read through it.
+type countedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedReader stops reading after n bytes, remembering whether the body
+// was truncated.
+type limitedReader struct {
+	r         io.Reader
+	n         int64
+	unlimited bool
+	truncated bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.unlimited {
+		return l.r.Read(p)
+	}
+	if l.n <= 0 {
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			l.truncated = true
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// sniffContentType detects the content type from the first bytes of a body.
+func sniffContentType(peek []byte) string {
+	if len(peek) >= 3 && string(peek[:3]) == "BZh" {
+		return "application/x-bzip2"
+	}
+	if isTar(peek) {
+		return "application/x-tar"
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(peek))
+	return contentType
+}
@@ -2,6 +2,7 @@ package crawler
 
 import (
 	"net/url"
+	"regexp"
 
 	"github.com/ganbarodigital/go_glob"
 )
@@ -66,3 +67,13 @@ func (c *chainedFilters) allow(u string) bool {
 	}
 	return true
 }
+
+// logoutFilter keeps links that would end the authenticated session out of
+// the crawl.
+type logoutFilter struct {
+	pattern *regexp.Regexp
+}
+
+func (l *logoutFilter) allow(u string) bool {
+	return !l.pattern.MatchString(u)
+}
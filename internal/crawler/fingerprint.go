@@ -0,0 +1,258 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+const (
+	simhashBands = 4
+	// pages with fewer features than this are only compared by exact hash,
+	// their simhash is too unstable to tell near duplicates apart.
+	minSimhashFeatures = 16
+)
+
+type fingerprint struct {
+	size     int64
+	sum      [sha256.Size]byte
+	simhash  uint64
+	features int
+}
+
+// fingerprinter computes the sha256 and simhash of a page as it is written,
+// simhash features are word bigrams.
+type fingerprinter struct {
+	size     int64
+	sha      hash.Hash
+	weights  [64]int
+	partial  []byte
+	word     []byte
+	prev     string
+	features int
+}
+
+func newFingerprinter() *fingerprinter {
+	return &fingerprinter{sha: sha256.New()}
+}
+
+func (f *fingerprinter) Write(p []byte) (int, error) {
+	n := len(p)
+	f.size += int64(n)
+	f.sha.Write(p)
+	if len(f.partial) > 0 {
+		p = append(f.partial, p...)
+		f.partial = nil
+	}
+	for len(p) > 0 {
+		if !utf8.FullRune(p) {
+			// rune split between two writes
+			f.partial = append([]byte{}, p...)
+			break
+		}
+		r, size := utf8.DecodeRune(p)
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			f.word = utf8.AppendRune(f.word, unicode.ToLower(r))
+		} else {
+			f.flushWord()
+		}
+		p = p[size:]
+	}
+	return n, nil
+}
+
+func (f *fingerprinter) flushWord() {
+	if len(f.word) == 0 {
+		return
+	}
+	word := string(f.word)
+	f.word = f.word[:0]
+	if len(f.prev) > 0 {
+		h := fnv.New64a()
+		h.Write([]byte(f.prev))
+		h.Write([]byte{' '})
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<i) != 0 {
+				f.weights[i]++
+			} else {
+				f.weights[i]--
+			}
+		}
+		f.features++
+	}
+	f.prev = word
+}
+
+func (f *fingerprinter) fingerprint() fingerprint {
+	f.flushWord()
+	fp := fingerprint{size: f.size, features: f.features}
+	copy(fp.sum[:], f.sha.Sum(nil))
+	for i, w := range f.weights {
+		if w > 0 {
+			fp.simhash |= 1 << i
+		}
+	}
+	return fp
+}
+
+type dedupEntry struct {
+	simhash uint64
+	cluster int
+}
+
+// dedupIndex groups pages serving the same or nearly the same content. Near
+// duplicates are looked up by splitting the simhash into bands, two hashes
+// within a distance lower than the number of bands share at least one band.
+type dedupIndex struct {
+	mu          sync.Mutex
+	enabled     bool
+	maxDistance int
+	exact       map[[sha256.Size]byte]int
+	bands       [simhashBands]map[uint16][]int
+	entries     []dedupEntry
+	clusters    [][]string
+	kinds       [][]string
+	hosts       []map[string]bool // hosts serving each cluster
+}
+
+func newDedupIndex(d *config.Dedup) *dedupIndex {
+	di := &dedupIndex{exact: map[[sha256.Size]byte]int{}}
+	for i := range di.bands {
+		di.bands[i] = map[uint16][]int{}
+	}
+	if d == nil {
+		return di
+	}
+	di.enabled = d.Enabled == nil || *d.Enabled
+	if d.MaxDistance != nil {
+		di.maxDistance = *d.MaxDistance
+	}
+	return di
+}
+
+// seen records the page and reports whether its content was already seen
+// under another url, along with the kind of duplicate. newHost tells that
+// the duplicate is the first of its cluster on its host, the findings of a
+// page belong to its host so they are still worth keeping.
+func (di *dedupIndex) seen(u string, fp fingerprint) (dup bool, kind string, newHost bool) {
+	if !di.enabled {
+		return false, "", false
+	}
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	if cluster, ok := di.exact[fp.sum]; ok {
+		return true, "exact", di.add(cluster, u, "exact")
+	}
+
+	nearby := di.maxDistance > 0 && fp.features >= minSimhashFeatures
+	if nearby {
+		for _, idx := range di.candidates(fp.simhash) {
+			e := di.entries[idx]
+			if bits.OnesCount64(e.simhash^fp.simhash) <= di.maxDistance {
+				di.exact[fp.sum] = e.cluster
+				return true, "near", di.add(e.cluster, u, "near")
+			}
+		}
+	}
+
+	cluster := len(di.clusters)
+	di.clusters = append(di.clusters, []string{})
+	di.kinds = append(di.kinds, []string{})
+	di.hosts = append(di.hosts, map[string]bool{})
+	di.exact[fp.sum] = cluster
+	di.add(cluster, u, "original")
+	if nearby {
+		idx := len(di.entries)
+		di.entries = append(di.entries, dedupEntry{simhash: fp.simhash, cluster: cluster})
+		for b := range di.bands {
+			key := band(fp.simhash, b)
+			di.bands[b][key] = append(di.bands[b][key], idx)
+		}
+	}
+	return false, "", true
+}
+
+// add records the url in the cluster and reports whether its host is new to
+// the cluster.
+func (di *dedupIndex) add(cluster int, u, kind string) bool {
+	di.clusters[cluster] = append(di.clusters[cluster], u)
+	di.kinds[cluster] = append(di.kinds[cluster], kind)
+	host := u
+	if parsed, err := url.Parse(u); err == nil {
+		host = parsed.Hostname()
+	}
+	if di.hosts[cluster][host] {
+		return false
+	}
+	di.hosts[cluster][host] = true
+	return true
+}
+
+func (di *dedupIndex) candidates(simhash uint64) []int {
+	if di.maxDistance >= simhashBands {
+		// banding can miss matches this far apart, compare with everything
+		all := make([]int, len(di.entries))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+	var idx []int
+	for b := range di.bands {
+		idx = append(idx, di.bands[b][band(simhash, b)]...)
+	}
+	return idx
+}
+
+func band(simhash uint64, b int) uint16 {
+	return uint16(simhash >> (16 * b))
+}
+
+// outputClusters writes every group of duplicated pages to duplicates.csv,
+// the first url of a cluster is the one that was scanned.
+func (di *dedupIndex) outputClusters(dir string) error {
+	if len(dir) == 0 || !di.enabled {
+		return nil
+	}
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	var rows [][]string
+	for i, urls := range di.clusters {
+		if len(urls) < 2 {
+			continue
+		}
+		for j, u := range urls {
+			rows = append(rows, []string{strconv.Itoa(i + 1), di.kinds[i][j], u})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	f, err := os.Create(filepath.Join(dir, "duplicates.csv"))
+	if err != nil {
+		return fmt.Errorf("failed to write duplicate clusters: %w", err)
+	}
+	defer f.Close()
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"cluster", "kind", "url"})
+	writer.WriteAll(rows)
+	writer.Flush()
+	return writer.Error()
+}
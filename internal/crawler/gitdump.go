@@ -0,0 +1,775 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// zeroSha stands for no commit in logs, such as the old value of the first
+// entry.
+const zeroSha = "0000000000000000000000000000000000000000"
+
+var errGitObjectLimit = errors.New("git object limit exceeded")
+
+// gitMetaFiles are requested from every exposed repository, refs found in
+// them lead to more files.
+var gitMetaFiles = []string{
+	"HEAD", "ORIG_HEAD", "FETCH_HEAD", "config", "packed-refs", "info/refs", "logs/HEAD", "index",
+	"objects/info/packs", "refs/heads/master", "refs/heads/main", "refs/heads/develop",
+	"refs/remotes/origin/HEAD", "refs/remotes/origin/master", "refs/remotes/origin/main", "refs/stash",
+}
+
+var (
+	shaPattern    = regexp.MustCompile(`\b[0-9a-f]{40}\b`)
+	packPattern   = regexp.MustCompile(`pack-[0-9a-f]{40}\.pack`)
+	branchPattern = regexp.MustCompile(`\[branch "([^"]+)"\]`)
+)
+
+// gitObjectTypes are the types of pack entries, deltas aside.
+var gitObjectTypes = map[byte]string{1: "commit", 2: "tree", 3: "blob", 4: "tag"}
+
+const (
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+type gitObject struct {
+	kind string
+	data []byte
+}
+
+// gitDumper rebuilds every exposed repository once.
+type gitDumper struct {
+	enabled    bool
+	maxObjects int
+	repos      sync.Map // .git urls dumped so far
+}
+
+func newGitDumper(g *config.Git) *gitDumper {
+	d := &gitDumper{}
+	if g == nil || g.Enabled == nil || !*g.Enabled {
+		return d
+	}
+	d.enabled = true
+	if g.MaxObjects != nil {
+		d.maxObjects = *g.MaxObjects
+	}
+	return d
+}
+
+// claim reports whether the repository at base is to be dumped, it is only
+// the case the first time an exposed HEAD is found for it.
+func (d *gitDumper) claim(headUrl string, head []byte) (string, bool) {
+	if !d.enabled || !isGitHead(head) {
+		return "", false
+	}
+	base := strings.TrimSuffix(headUrl, "/HEAD")
+	if _, loaded := d.repos.LoadOrStore(base, struct{}{}); loaded {
+		return "", false
+	}
+	return base, true
+}
+
+func isGitHead(head []byte) bool {
+	head = bytes.TrimSpace(head)
+	return bytes.HasPrefix(head, []byte("ref: refs/")) || shaPattern.Match(head) && len(head) == 40
+}
+
+// gitRepo is an exposed repository being rebuilt, under dir when results are
+// written to disk.
+type gitRepo struct {
+	c          *Crawler
+	ctx        context.Context
+	base       string // url of the .git directory
+	dir        string
+	maxObjects int
+	limits     archiveLimits // bound every inflated object
+	requested  int
+	objects    map[string]*gitObject
+	tips       []string // shas found in refs and logs
+	index      []gitIndexEntry
+	packs      []string
+}
+
+type gitIndexEntry struct {
+	path string
+	sha  string
+}
+
+// dumpGit walks the repository exposed at base, rebuilds it locally and
+// scans every blob of its history, the blobs of the index and whatever else
+// its packs hold.
+func (c *Crawler) dumpGit(ctx context.Context, base, hostname string) ([]Secret, error) {
+	repo := &gitRepo{
+		c:          c,
+		ctx:        ctx,
+		base:       base,
+		maxObjects: c.git.maxObjects,
+		limits:     c.processors.archive,
+		objects:    map[string]*gitObject{},
+	}
+	if len(c.config.Output) > 0 {
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, err
+		}
+		// repositories of the same host are told apart by their path
+		prefix := path.Clean("/" + strings.TrimSuffix(u.Path, "/.git"))
+		repo.dir = filepath.Join(c.config.Output, "git", strings.ReplaceAll(u.Host, ":", "_"), filepath.FromSlash(prefix), ".git")
+	}
+	repo.fetchMeta()
+	repo.init()
+	for _, name := range repo.packs {
+		if err := repo.fetchPack(name); err != nil {
+			c.logger.Debug().Err(err).Msg(fmt.Sprintf("Skipping pack %s/objects/pack/%s", base, name))
+		}
+	}
+
+	sink := newScanSink(base, c.processors, c.rules, make([]byte, 0, scanChunkSize+scanOverlap))
+	guard := &bombGuard{}
+	scanned := map[string]bool{}
+	scan := func(sha, name string) {
+		o, err := repo.object(sha)
+		if err != nil || o.kind != "blob" || scanned[sha] {
+			return
+		}
+		scanned[sha] = true
+		if err := processEntry(bytes.NewReader(o.data), name, guard, sink); err != nil {
+			c.logs.scan.Debug().Err(err).Msg(fmt.Sprintf("Error while scanning %s!%s", base, name))
+		}
+	}
+
+	err := repo.walk(scan)
+	for _, e := range repo.index {
+		scan(e.sha, "index:"+e.path)
+	}
+	// blobs of packs that no commit reaches anymore
+	for sha, o := range repo.objects {
+		if o.kind == "blob" {
+			scan(sha, sha)
+		}
+	}
+	sink.close()
+
+	c.logger.Info().Msg(fmt.Sprintf("Rebuilt %s: %d objects, %d blobs scanned", base, len(repo.objects), len(scanned)))
+	return sinkSecrets(hostname, sink), err
+}
+
+// fetchMeta requests the refs, logs and index of the repository, refs they
+// mention are requested in turn.
+func (r *gitRepo) fetchMeta() {
+	files := append([]string{}, gitMetaFiles...)
+	seen := map[string]bool{}
+	for i := 0; i < len(files); i++ {
+		name := files[i]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		data, err := r.fetch(name)
+		if err != nil {
+			continue
+		}
+		text := string(data)
+		switch name {
+		case "index":
+			if r.index = parseGitIndex(data); r.index != nil {
+				r.save(name, data)
+			}
+			continue
+		case "config":
+			if strings.Contains(text, "[core]") {
+				r.save(name, data)
+			}
+			for _, m := range branchPattern.FindAllStringSubmatch(text, -1) {
+				files = append(files, "refs/heads/"+m[1], "logs/refs/heads/"+m[1])
+			}
+			continue
+		case "objects/info/packs":
+			r.addPacks(text) // packs are only kept along with their index
+			continue
+		}
+
+		ref, isSymbolic := strings.CutPrefix(strings.TrimSpace(text), "ref: ")
+		shas := shaPattern.FindAllString(text, -1)
+		if !isSymbolic && len(shas) == 0 {
+			continue // most likely a not found page
+		}
+		r.save(name, data)
+		if isSymbolic {
+			files = append(files, ref, "logs/"+ref)
+		}
+		for _, sha := range shas {
+			if sha != zeroSha {
+				r.tips = append(r.tips, sha)
+			}
+		}
+	}
+
+	// servers with directory listing give the packs away
+	if listing, err := r.fetch("objects/pack/"); err == nil {
+		r.addPacks(string(listing))
+	}
+}
+
+// init creates the directories git needs to recognize the rebuilt
+// repository, refs may all be packed.
+func (r *gitRepo) init() {
+	if len(r.dir) == 0 {
+		return
+	}
+	for _, dir := range []string{"refs/heads", "refs/tags", "objects/pack"} {
+		if err := os.MkdirAll(filepath.Join(r.dir, filepath.FromSlash(dir)), 0o755); err != nil {
+			r.c.logger.Debug().Err(err).Msg(fmt.Sprintf("Failed to create %s", dir))
+		}
+	}
+}
+
+func (r *gitRepo) addPacks(text string) {
+	for _, name := range packPattern.FindAllString(text, -1) {
+		if !slices.Contains(r.packs, name) {
+			r.packs = append(r.packs, name)
+		}
+	}
+}
+
+// fetch requests a file of the repository, truncated files are of no use.
+func (r *gitRepo) fetch(name string) ([]byte, error) {
+	resp, err := r.c.req(r.base+"/"+name, r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	data, err := io.ReadAll(resp.body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.body.truncated {
+		return nil, fmt.Errorf("%s truncated to %d bytes", name, len(data))
+	}
+	return data, nil
+}
+
+// save writes a file of the rebuilt repository, names come from the server
+// and are kept inside the repository.
+func (r *gitRepo) save(name string, data []byte) {
+	if len(r.dir) == 0 || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return
+	}
+	dst := filepath.Join(r.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		r.c.logger.Debug().Err(err).Msg(fmt.Sprintf("Failed to write %s", dst))
+		return
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		r.c.logger.Debug().Err(err).Msg(fmt.Sprintf("Failed to write %s", dst))
+	}
+}
+
+// fetchPack reads every object of a pack, the pack is kept as it is when
+// its index is available and written as loose objects otherwise.
+func (r *gitRepo) fetchPack(name string) error {
+	data, err := r.fetch("objects/pack/" + name)
+	if err != nil {
+		return err
+	}
+	objects, err := parsePack(data, r.objects, r.limits)
+	if err != nil {
+		return err
+	}
+	idxName := strings.TrimSuffix(name, ".pack") + ".idx"
+	idx, idxErr := r.fetch("objects/pack/" + idxName)
+	if idxErr == nil {
+		r.save("objects/pack/"+name, data)
+		r.save("objects/pack/"+idxName, idx)
+	}
+	for sha, o := range objects {
+		r.objects[sha] = o
+		if idxErr != nil {
+			r.saveObject(sha, o)
+		}
+	}
+	return nil
+}
+
+func (r *gitRepo) saveObject(sha string, o *gitObject) {
+	if len(r.dir) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	fmt.Fprintf(zw, "%s %d\x00", o.kind, len(o.data))
+	zw.Write(o.data)
+	zw.Close()
+	r.save("objects/"+sha[:2]+"/"+sha[2:], buf.Bytes())
+}
+
+// object returns the object from the packs, or requests it as a loose
+// object.
+func (r *gitRepo) object(sha string) (*gitObject, error) {
+	if o, ok := r.objects[sha]; ok {
+		return o, nil
+	}
+	if !shaPattern.MatchString(sha) || len(sha) != 40 {
+		return nil, fmt.Errorf("invalid object id %q", sha)
+	}
+	if r.maxObjects > 0 && r.requested >= r.maxObjects {
+		return nil, fmt.Errorf("%w: more than %d objects requested", errGitObjectLimit, r.maxObjects)
+	}
+	r.requested++
+	name := "objects/" + sha[:2] + "/" + sha[2:]
+	raw, err := r.fetch(name)
+	if err != nil {
+		return nil, err
+	}
+	o, err := parseLooseObject(raw, r.limits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object %s: %w", sha, err)
+	}
+	if hashGitObject(o) != sha {
+		return nil, fmt.Errorf("object %s doesn't match its hash", sha)
+	}
+	r.save(name, raw)
+	r.objects[sha] = o
+	return o, nil
+}
+
+// walk goes through the history from the refs, every blob is handed to scan
+// along with the commit and path it was first seen at. Refs are walked from
+// the newest commits so the most recent path is reported.
+func (r *gitRepo) walk(scan func(sha, name string)) error {
+	queue := append([]string{}, r.tips...)
+	visited := map[string]bool{}
+	for len(queue) > 0 {
+		sha := queue[0]
+		queue = queue[1:]
+		if visited[sha] {
+			continue
+		}
+		visited[sha] = true
+		o, err := r.object(sha)
+		if errors.Is(err, errGitObjectLimit) {
+			return err
+		}
+		if err != nil {
+			r.c.logger.Debug().Err(err).Msg(fmt.Sprintf("Missing object %s in %s", sha, r.base))
+			continue
+		}
+		switch o.kind {
+		case "commit":
+			tree, parents := parseCommit(o.data)
+			queue = append(queue, parents...)
+			if err := r.walkTree(tree, sha[:12]+":", visited, scan); err != nil {
+				return err
+			}
+		case "tag":
+			if target, ok := gitHeader(o.data, "object"); ok {
+				queue = append(queue, target)
+			}
+		}
+	}
+	return nil
+}
+
+// walkTree hands the blobs of the tree to scan, trees already walked from a
+// newer commit are skipped.
+func (r *gitRepo) walkTree(sha, prefix string, visited map[string]bool, scan func(sha, name string)) error {
+	if len(sha) == 0 || visited[sha] {
+		return nil
+	}
+	visited[sha] = true
+	o, err := r.object(sha)
+	if errors.Is(err, errGitObjectLimit) {
+		return err
+	}
+	if err != nil || o.kind != "tree" {
+		r.c.logger.Debug().Err(err).Msg(fmt.Sprintf("Missing tree %s in %s", sha, r.base))
+		return nil
+	}
+	for _, e := range parseTree(o.data) {
+		switch e.mode {
+		case "40000":
+			if err := r.walkTree(e.sha, prefix+e.name+"/", visited, scan); err != nil {
+				return err
+			}
+		case "160000":
+			// submodules live in another repository
+		default:
+			scan(e.sha, prefix+e.name)
+		}
+	}
+	return nil
+}
+
+func hashGitObject(o *gitObject) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", o.kind, len(o.data))
+	h.Write(o.data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// inflate reads a zlib stream, bounded by the archive limits. The reader is
+// left right at the end of the stream.
+func inflate(r *bytes.Reader, limits archiveLimits) ([]byte, error) {
+	guard := &bombGuard{limits: limits}
+	zr, err := zlib.NewReader(&countingByteReader{Reader: r, guard: guard})
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(&guardedReader{r: zr, guard: guard})
+}
+
+// countingByteReader counts the compressed bytes of a stream, as a
+// io.ByteReader zlib doesn't read past its end.
+type countingByteReader struct {
+	*bytes.Reader
+	guard *bombGuard
+}
+
+func (c *countingByteReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.guard.compressed += int64(n)
+	return n, err
+}
+
+func (c *countingByteReader) ReadByte() (byte, error) {
+	b, err := c.Reader.ReadByte()
+	if err == nil {
+		c.guard.compressed++
+	}
+	return b, err
+}
+
+func parseLooseObject(raw []byte, limits archiveLimits) (*gitObject, error) {
+	data, err := inflate(bytes.NewReader(raw), limits)
+	if err != nil {
+		return nil, err
+	}
+	header, body, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return nil, errors.New("missing object header")
+	}
+	kind, size, _ := strings.Cut(string(header), " ")
+	if n, err := strconv.Atoi(size); err != nil || n != len(body) {
+		return nil, errors.New("invalid object size")
+	}
+	return &gitObject{kind: kind, data: body}, nil
+}
+
+// gitHeader returns the value of a commit or tag header.
+func gitHeader(data []byte, name string) (string, bool) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(line) == 0 {
+			break // headers end at the first blank line
+		}
+		if v, ok := strings.CutPrefix(line, name+" "); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func parseCommit(data []byte) (tree string, parents []string) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(line) == 0 {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "tree "); ok {
+			tree = v
+		} else if v, ok := strings.CutPrefix(line, "parent "); ok {
+			parents = append(parents, v)
+		}
+	}
+	return tree, parents
+}
+
+type gitTreeEntry struct {
+	mode string
+	name string
+	sha  string
+}
+
+// parseTree reads "mode name\0<20 bytes sha>" entries.
+func parseTree(data []byte) []gitTreeEntry {
+	var entries []gitTreeEntry
+	for len(data) > 0 {
+		header, rest, ok := bytes.Cut(data, []byte{0})
+		if !ok || len(rest) < 20 {
+			break
+		}
+		mode, name, _ := strings.Cut(string(header), " ")
+		entries = append(entries, gitTreeEntry{mode: mode, name: name, sha: hex.EncodeToString(rest[:20])})
+		data = rest[20:]
+	}
+	return entries
+}
+
+// parseGitIndex reads the paths and blobs of a version 2 or 3 index, nil is
+// returned for anything else.
+func parseGitIndex(data []byte) []gitIndexEntry {
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version != 2 && version != 3 {
+		return nil
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+	entries := []gitIndexEntry{}
+	pos := 12
+	for i := uint32(0); i < count; i++ {
+		start := pos
+		// ctime, mtime, dev, ino, mode, uid, gid and size come before the sha
+		if pos+62 > len(data) {
+			break
+		}
+		sha := hex.EncodeToString(data[pos+40 : pos+60])
+		flags := binary.BigEndian.Uint16(data[pos+60 : pos+62])
+		pos += 62
+		if version == 3 && flags&0x4000 != 0 {
+			pos += 2
+		}
+		if pos > len(data) {
+			break
+		}
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			break
+		}
+		entries = append(entries, gitIndexEntry{path: string(data[pos : pos+end]), sha: sha})
+		// entries are padded with 1 to 8 nul bytes to a multiple of 8
+		pos = start + (pos+end-start+8)&^7
+	}
+	return entries
+}
+
+type packEntry struct {
+	kind    byte
+	data    []byte
+	baseOfs int
+	baseSha string
+}
+
+// parsePack reads every object of a pack, deltas are resolved against the
+// pack itself or the objects already known.
+func parsePack(data []byte, known map[string]*gitObject, limits archiveLimits) (map[string]*gitObject, error) {
+	if len(data) < 12 || string(data[:4]) != "PACK" {
+		return nil, errors.New("not a pack file")
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+	// every object takes at least a header byte and a zlib stream
+	if int64(count) > int64(len(data)-12)/minPackEntry {
+		return nil, fmt.Errorf("pack declares %d objects in %d bytes", count, len(data))
+	}
+	entries := map[int]*packEntry{}
+	order := make([]int, 0, count)
+	pos := 12
+	for i := uint32(0); i < count; i++ {
+		offset := pos
+		if pos >= len(data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := data[pos]
+		pos++
+		e := &packEntry{kind: (b >> 4) & 7}
+		for b&0x80 != 0 { // size, the inflated data tells it anyway
+			if pos >= len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			b = data[pos]
+			pos++
+		}
+		switch e.kind {
+		case packOfsDelta:
+			if pos >= len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			b = data[pos]
+			pos++
+			ofs := int(b & 0x7f)
+			for b&0x80 != 0 {
+				if pos >= len(data) {
+					return nil, io.ErrUnexpectedEOF
+				}
+				b = data[pos]
+				pos++
+				ofs = (ofs+1)<<7 | int(b&0x7f)
+			}
+			e.baseOfs = offset - ofs
+		case packRefDelta:
+			if pos+20 > len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			e.baseSha = hex.EncodeToString(data[pos : pos+20])
+			pos += 20
+		}
+
+		// bytes.Reader lets zlib stop right at the end of the stream
+		br := bytes.NewReader(data[pos:])
+		var err error
+		if e.data, err = inflate(br, limits); err != nil {
+			return nil, err
+		}
+		pos = len(data) - br.Len()
+		entries[offset] = e
+		order = append(order, offset)
+	}
+
+	objects := map[string]*gitObject{}
+	resolved := map[int]*gitObject{}
+	// ref deltas may refer to objects further in the pack, keep going as
+	// long as something gets resolved
+	for progress := true; progress; {
+		progress = false
+		for _, offset := range order {
+			if resolved[offset] != nil {
+				continue
+			}
+			o, err := resolvePackEntry(offset, entries, resolved, objects, known, limits, 0)
+			if err != nil || o == nil {
+				continue
+			}
+			objects[hashGitObject(o)] = o
+			progress = true
+		}
+	}
+	return objects, nil
+}
+
+// minPackEntry is the size of the smallest object of a pack, a header byte
+// and an empty zlib stream.
+const minPackEntry = 9
+
+// maxDeltaChain matches the longest chains git itself writes.
+const maxDeltaChain = 4095
+
+func resolvePackEntry(offset int, entries map[int]*packEntry, resolved map[int]*gitObject, objects, known map[string]*gitObject, limits archiveLimits, depth int) (*gitObject, error) {
+	if o := resolved[offset]; o != nil {
+		return o, nil
+	}
+	e := entries[offset]
+	if e == nil || depth > maxDeltaChain {
+		return nil, errors.New("invalid delta base")
+	}
+	var base *gitObject
+	switch e.kind {
+	case packOfsDelta:
+		b, err := resolvePackEntry(e.baseOfs, entries, resolved, objects, known, limits, depth+1)
+		if err != nil || b == nil {
+			return nil, err
+		}
+		base = b
+	case packRefDelta:
+		if base = objects[e.baseSha]; base == nil {
+			base = known[e.baseSha]
+		}
+		if base == nil {
+			return nil, nil // not resolved yet
+		}
+	default:
+		kind, ok := gitObjectTypes[e.kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown object type %d", e.kind)
+		}
+		o := &gitObject{kind: kind, data: e.data}
+		resolved[offset] = o
+		return o, nil
+	}
+	data, err := applyDelta(base.data, e.data, limits.maxTotalSize)
+	if err != nil {
+		return nil, err
+	}
+	o := &gitObject{kind: base.kind, data: data}
+	resolved[offset] = o
+	return o, nil
+}
+
+// applyDelta rebuilds an object from its base and the copy and insert
+// instructions of its delta, an object larger than maxSize is rejected.
+func applyDelta(base, delta []byte, maxSize int64) ([]byte, error) {
+	errDelta := errors.New("invalid delta")
+	varint := func() (int, bool) {
+		n, shift := 0, 0
+		for len(delta) > 0 && shift < 63 {
+			b := delta[0]
+			delta = delta[1:]
+			n |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				return n, true
+			}
+		}
+		return 0, false
+	}
+	srcSize, ok := varint()
+	if !ok || srcSize != len(base) {
+		return nil, errDelta
+	}
+	dstSize, ok := varint()
+	if !ok || dstSize < 0 {
+		return nil, errDelta
+	}
+	if maxSize > 0 && int64(dstSize) > maxSize {
+		return nil, fmt.Errorf("%w: delta declares %d bytes", errArchiveLimit, dstSize)
+	}
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 && len(out) <= dstSize {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			var offset, size int
+			for i := 0; i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errDelta
+				}
+				if i < 4 {
+					offset |= int(delta[0]) << (8 * i)
+				} else {
+					size |= int(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, errDelta
+			}
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errDelta
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errDelta
+		}
+	}
+	if len(out) != dstSize {
+		return nil, errDelta
+	}
+	return out, nil
+}
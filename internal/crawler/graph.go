@@ -0,0 +1,230 @@
+package crawler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// graphWriters write the link graph in the formats it can be exported to,
+// the format is also the extension of the written file.
+var graphWriters = map[string]func(g *linkGraph, w io.Writer) error{
+	"dot":     writeDot,
+	"graphml": writeGraphML,
+	"json":    writeGraphJSON,
+}
+
+type graphNode struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status,omitempty"` // 0 when the url wasn't requested
+	ContentType string `json:"contentType,omitempty"`
+	Depth       int    `json:"depth"`
+}
+
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Tag  string `json:"tag,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+// linkGraph records which page links to which, to show the structure of a
+// site and how a leaking page was reached.
+type linkGraph struct {
+	formats []string
+	mu      sync.Mutex
+	nodes   map[string]*graphNode
+	order   []string // urls in discovery order
+	edges   []graphEdge
+	seen    map[graphEdge]bool // edges without their text
+}
+
+func newLinkGraph(formats []string) (*linkGraph, error) {
+	for _, f := range formats {
+		if _, ok := graphWriters[f]; !ok {
+			return nil, fmt.Errorf("unknown graph format %q", f)
+		}
+	}
+	return &linkGraph{
+		formats: formats,
+		nodes:   map[string]*graphNode{},
+		seen:    map[graphEdge]bool{},
+	}, nil
+}
+
+func (g *linkGraph) enabled() bool {
+	return len(g.formats) > 0
+}
+
+// node returns the node of the url, the lowest depth it was found at wins.
+// The lock must be held.
+func (g *linkGraph) node(u string, depth int) *graphNode {
+	n, ok := g.nodes[u]
+	if !ok {
+		n = &graphNode{URL: u, Depth: depth}
+		g.nodes[u] = n
+		g.order = append(g.order, u)
+	}
+	if depth < n.Depth {
+		n.Depth = depth
+	}
+	return n
+}
+
+// visit records the response of a crawled url.
+func (g *linkGraph) visit(u string, depth, status int, contentType string) {
+	if !g.enabled() {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := g.node(u, depth)
+	n.Status = status
+	n.ContentType = contentType
+}
+
+// link records a reference from a page crawled at depth.
+func (g *linkGraph) link(from string, r ref, depth int) {
+	if !g.enabled() {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := graphEdge{From: from, To: r.url, Tag: r.tag}
+	if g.seen[key] {
+		return
+	}
+	g.seen[key] = true
+	g.node(from, depth)
+	g.node(r.url, depth+1)
+	g.edges = append(g.edges, graphEdge{From: from, To: r.url, Tag: r.tag, Text: r.text})
+}
+
+// output writes the graph to graph.<format> for every configured format.
+func (g *linkGraph) output(dir string) error {
+	if len(dir) == 0 || !g.enabled() {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, format := range g.formats {
+		f, err := os.Create(filepath.Join(dir, "graph."+format))
+		if err != nil {
+			return fmt.Errorf("failed to write link graph: %w", err)
+		}
+		err = graphWriters[format](g, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write %s link graph: %w", format, err)
+		}
+	}
+	return nil
+}
+
+func writeDot(g *linkGraph, w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph links {\n")
+	for _, u := range g.order {
+		n := g.nodes[u]
+		fmt.Fprintf(&sb, "  %s [status=%d, content_type=%s, depth=%d];\n", dotQuote(n.URL), n.Status, dotQuote(n.ContentType), n.Depth)
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&sb, "  %s -> %s [tag=%s, label=%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(e.Tag), dotQuote(e.Text))
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// writeGraphML uses the urls as node ids.
+func writeGraphML(g *linkGraph, w io.Writer) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "status", For: "node", Name: "status", Type: "int"},
+			{ID: "contentType", For: "node", Name: "contentType", Type: "string"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+			{ID: "tag", For: "edge", Name: "tag", Type: "string"},
+			{ID: "text", For: "edge", Name: "text", Type: "string"},
+		},
+	}
+	doc.Graph.ID = "links"
+	doc.Graph.EdgeDefault = "directed"
+	for _, u := range g.order {
+		n := g.nodes[u]
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.URL, Data: []graphMLData{
+			{Key: "status", Value: fmt.Sprint(n.Status)},
+			{Key: "contentType", Value: n.ContentType},
+			{Key: "depth", Value: fmt.Sprint(n.Depth)},
+		}})
+	}
+	for _, e := range g.edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e.From, Target: e.To, Data: []graphMLData{
+			{Key: "tag", Value: e.Tag},
+			{Key: "text", Value: e.Text},
+		}})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+func writeGraphJSON(g *linkGraph, w io.Writer) error {
+	nodes := make([]*graphNode, 0, len(g.order))
+	for _, u := range g.order {
+		nodes = append(nodes, g.nodes[u])
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Nodes []*graphNode `json:"nodes"`
+		Edges []graphEdge  `json:"edges"`
+	}{Nodes: nodes, Edges: g.edges})
+}
@@ -0,0 +1,235 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// harFile holds the parts of a HAR archive the crawler makes use of.
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method  string      `json:"method"`
+		URL     string      `json:"url"`
+		Headers []harHeader `json:"headers"`
+		Cookies []harCookie `json:"cookies"`
+	} `json:"request"`
+	Response struct {
+		Status     int         `json:"status"`
+		StatusText string      `json:"statusText"`
+		Headers    []harHeader `json:"headers"`
+		Cookies    []harCookie `json:"cookies"`
+		Content    struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path"`
+	Domain   string `json:"domain"`
+	Expires  string `json:"expires"`
+	HTTPOnly bool   `json:"httpOnly"`
+	Secure   bool   `json:"secure"`
+}
+
+func readHarFile(src string) (*harFile, error) {
+	raw, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	har := &harFile{}
+	if err := json.Unmarshal(raw, har); err != nil {
+		return nil, fmt.Errorf("invalid har file: %w", err)
+	}
+	return har, nil
+}
+
+// readHar calls fn with the response of every entry of the HAR file at src,
+// entries have no record id.
+func readHar(src string, fn func(resp *http.Response, record string) error) error {
+	har, err := readHarFile(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range har.Log.Entries {
+		resp, err := e.response()
+		if err != nil {
+			continue
+		}
+		if err := fn(resp, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// response rebuilds the http response of the entry, the content of HAR
+// files is already decoded.
+func (e *harEntry) response() (*http.Response, error) {
+	method := e.Request.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, e.Request.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range e.Request.Headers {
+		if !strings.HasPrefix(h.Name, ":") { // http/2 pseudo headers
+			req.Header.Add(h.Name, h.Value)
+		}
+	}
+
+	body := []byte(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		if body, err = base64.StdEncoding.DecodeString(e.Response.Content.Text); err != nil {
+			return nil, fmt.Errorf("invalid har content: %w", err)
+		}
+	}
+	header := http.Header{}
+	for _, h := range e.Response.Headers {
+		switch strings.ToLower(h.Name) {
+		case "content-encoding", "content-length", "transfer-encoding":
+		default:
+			if !strings.HasPrefix(h.Name, ":") {
+				header.Add(h.Name, h.Value)
+			}
+		}
+	}
+	if len(header.Get("Content-Type")) == 0 && len(e.Response.Content.MimeType) > 0 {
+		header.Set("Content-Type", e.Response.Content.MimeType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText),
+		StatusCode:    e.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// loadHarCookies seeds the jar with the cookies sent and set by the entries
+// of the HAR file at src, in the order they were captured.
+func loadHarCookies(jar *cookiejar.Jar, src string) error {
+	har, err := readHarFile(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range har.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			continue
+		}
+		for _, hc := range e.Request.Cookies {
+			setCookie(jar, u.Hostname(), &http.Cookie{Name: hc.Name, Value: hc.Value, Secure: u.Scheme == "https"})
+		}
+		var set []*http.Cookie
+		for _, hc := range e.Response.Cookies {
+			ck := &http.Cookie{
+				Name:     hc.Name,
+				Value:    hc.Value,
+				Path:     hc.Path,
+				Domain:   hc.Domain,
+				HttpOnly: hc.HTTPOnly,
+				Secure:   hc.Secure,
+			}
+			// browsers don't agree on the format, session cookies are kept
+			// when it can't be parsed
+			if expires, err := time.Parse(time.RFC3339, hc.Expires); err == nil {
+				ck.Expires = expires
+			}
+			set = append(set, ck)
+		}
+		jar.SetCookies(u, set)
+	}
+	return nil
+}
+
+// importHar scans the entries of the HAR file as already fetched pages:
+// their headers and bodies are run through the rules and their urls aren't
+// requested again. The urls they link to are returned as jobs when seeding
+// is enabled.
+func (c *Crawler) importHar(buf []byte) ([]job, []Secret, error) {
+	h := c.config.Har
+	if h == nil || len(h.File) == 0 {
+		return nil, nil, nil
+	}
+	har, err := readHarFile(h.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	seed := h.Seed != nil && *h.Seed
+
+	var responses []*http.Response
+	for _, e := range har.Log.Entries {
+		resp, err := e.response()
+		if err != nil || !c.filters.allow(e.Request.URL) {
+			continue
+		}
+		responses = append(responses, resp)
+		if key, err := c.canon.canonicalize(e.Request.URL); err == nil {
+			c.jq.isVisited(key)
+		}
+		if seed {
+			c.jq.storeBasePath(e.Request.URL)
+		}
+	}
+
+	var jobs []job
+	var secrets []Secret
+	for _, resp := range responses {
+		target, hostname := resp.Request.URL.String(), resp.Request.URL.Hostname()
+		pNode := c.scanArchived(newResponse(resp, c.limits), "", buf)
+		if pNode == nil {
+			pNode = newPageNode(target, c.rules)
+		}
+		pNode.scanHeaders(hostname, "request-headers", resp.Request.Header)
+		pNode.scanHeaders(hostname, "response-headers", resp.Header)
+		secrets = append(secrets, pNode.foundSecrets...)
+		if !seed {
+			continue
+		}
+		for _, ref := range pNode.foundRefs {
+			key, err := c.canon.canonicalize(ref.url)
+			if err != nil || !c.filters.allow(ref.url) || c.jq.isVisited(key) {
+				continue
+			}
+			if reason := c.traps.trapped(key); len(reason) > 0 {
+				c.logs.queue.Debug().Msg(fmt.Sprintf("Dropping %s, possible crawler trap: %s", ref.url, reason))
+				continue
+			}
+			jobs = append(jobs, job{url: ref.url, depth: 2})
+			jobs = append(jobs, c.probeJobs(ref.url, 1)...)
+		}
+	}
+	c.logger.Info().Msg(fmt.Sprintf("%d entries imported from %s", len(responses), h.File))
+	return jobs, secrets, nil
+}
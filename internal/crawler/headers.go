@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+type headerSet struct {
+	defaults      map[string]string
+	domainHeaders []config.DomainHeaders
+	userAgents    []string
+	next          uint64
+}
+
+func newHeaderSet(c config.Config) *headerSet {
+	return &headerSet{
+		defaults:      c.Headers,
+		domainHeaders: c.DomainHeaders,
+		userAgents:    c.UserAgents,
+	}
+}
+
+// apply sets the default headers, then the headers of every matching domain
+// so that more specific values win, and finally rotates the user agent unless
+// one has been set explicitly.
+func (h *headerSet) apply(req *http.Request) {
+	for k, v := range h.defaults {
+		req.Header.Set(k, v)
+	}
+	hostname := req.URL.Hostname()
+	for _, dh := range h.domainHeaders {
+		if !matchGlob(dh.Domain, hostname) {
+			continue
+		}
+		for k, v := range dh.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	if len(h.userAgents) > 0 && len(req.Header.Get("User-Agent")) == 0 {
+		n := atomic.AddUint64(&h.next, 1) - 1
+		req.Header.Set("User-Agent", h.userAgents[n%uint64(len(h.userAgents))])
+	}
+}
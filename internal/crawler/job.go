@@ -1,21 +1,31 @@
 package crawler
 
 import (
+	"cmp"
 	"encoding/csv"
 	"fmt"
+	"maps"
 	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"github.com/got-many-wheels/spoderman/internal/metrics"
 	"github.com/hashicorp/go-memdb"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type job struct {
-	url   string
-	depth int
+	url    string
+	depth  int
+	probe  bool              // sensitive path probe, see prober
+	from   string            // url of the page the url was found on
+	parent trace.SpanContext // span of that page
 }
 
 type jobQueue struct {
@@ -28,6 +38,10 @@ type jobQueue struct {
 	sm        sync.Map
 	jwg       sync.WaitGroup // jobs wait group
 	db        *memdb.MemDB
+	findings  map[string]int64 // distinct secrets per rule
+	metrics   *metrics.Metrics
+	found     func(Secret) // called once for every distinct secret
+	minRank   int          // index in config.Severities of the least severe secrets kept
 
 	// interval tings...
 	withTicker bool
@@ -36,7 +50,7 @@ type jobQueue struct {
 }
 
 func newJobQueue(withInterval int) *jobQueue {
-	jq := &jobQueue{}
+	jq := &jobQueue{findings: map[string]int64{}, minRank: len(config.Severities) - 1}
 
 	if withInterval > 0 {
 		jq.withTicker = true
@@ -90,6 +104,16 @@ func (jq *jobQueue) enqueue(jobs []job, foundSecrets []Secret) {
 
 	txn := jq.db.Txn(true)
 	for _, secret := range foundSecrets {
+		if rank(config.Severities, secret.Severity) > jq.minRank {
+			continue
+		}
+		if existing, _ := txn.First("secret", "id", secret.ID); existing == nil {
+			jq.findings[secret.Key]++
+			jq.metrics.Finding(secret.Key, secret.Severity)
+			if jq.found != nil {
+				jq.found(secret)
+			}
+		}
 		if err := txn.Insert("secret", secret); err != nil {
 			panic(err)
 		}
@@ -104,6 +128,7 @@ func (jq *jobQueue) enqueue(jobs []job, foundSecrets []Secret) {
 		}
 		jq.queue = append(jq.queue, j)
 	}
+	jq.metrics.QueueDepth(len(jq.queue))
 	jq.cond.Broadcast()
 }
 
@@ -131,6 +156,7 @@ func (jq *jobQueue) dequeue() (job, bool) {
 			}
 			j := jq.queue[0]
 			jq.queue = jq.queue[1:]
+			jq.metrics.QueueDepth(len(jq.queue))
 			return j, true
 		case <-jq.tickerDone:
 			jq.mu.Lock()
@@ -140,9 +166,17 @@ func (jq *jobQueue) dequeue() (job, bool) {
 
 	j := jq.queue[0]
 	jq.queue = jq.queue[1:]
+	jq.metrics.QueueDepth(len(jq.queue))
 	return j, true
 }
 
+// progress returns the number of queued jobs and of secrets found per rule.
+func (jq *jobQueue) progress() (int, map[string]int64) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	return len(jq.queue), maps.Clone(jq.findings)
+}
+
 func (jq *jobQueue) stopTickerChan() {
 	if jq.withTicker {
 		close(jq.tickerDone)
@@ -164,9 +198,11 @@ func (jq *jobQueue) storeBasePath(initialUrl string) error {
 	return nil
 }
 
-func (jq *jobQueue) outputResults(cfgPath string) error {
+// outputResults writes the secrets of every host to <host>.csv, as returned
+// by redact, and returns the names of the written files.
+func (jq *jobQueue) outputResults(cfgPath string, redact func(Secret) Secret) ([]string, error) {
 	if len(cfgPath) == 0 {
-		return nil
+		return nil, nil
 	}
 	txn := jq.db.Txn(false)
 	defer txn.Abort()
@@ -176,20 +212,21 @@ func (jq *jobQueue) outputResults(cfgPath string) error {
 		panic(err)
 	}
 
-	m := make(map[string][][]string)
+	m := make(map[string][]Secret)
 
 	for obj := it.Next(); obj != nil; obj = it.Next() {
-		p := obj.(Secret)
+		p := redact(obj.(Secret))
 		parts := strings.Split(p.ID, ":")
-		_, ok := m[parts[0]]
-		if !ok {
-			m[parts[0]] = [][]string{}
-		}
-		val := []string{p.Key, p.Value}
-		m[parts[0]] = append(m[parts[0]], val)
+		m[parts[0]] = append(m[parts[0]], p)
 	}
 
-	for hostname, secret := range m {
+	var files []string
+	for hostname, secrets := range m {
+		slices.SortStableFunc(secrets, compareSecrets)
+		var rows [][]string
+		for _, p := range secrets {
+			rows = append(rows, []string{p.Key, p.Value, p.Source, p.Severity, p.Fingerprint, p.WarcRecord, p.Category, p.Confidence, p.CWE})
+		}
 		counter := 1
 		filename := fmt.Sprintf("%s/%s.csv", cfgPath, hostname)
 		for {
@@ -203,16 +240,50 @@ func (jq *jobQueue) outputResults(cfgPath string) error {
 
 		f, err := os.Create(filename)
 		if err != nil {
-			return err
+			return files, err
 		}
+		files = append(files, filepath.Base(filename))
 		defer f.Close()
 		writer := csv.NewWriter(f)
-		writer.Write([]string{"secret_key", "value"})
-		writer.WriteAll(secret)
+		writer.Write([]string{"secret_key", "value", "source", "severity", "fingerprint", "warc_record", "category", "confidence", "cwe"})
+		writer.WriteAll(rows)
 		writer.Flush()
 	}
 
-	return nil
+	return files, nil
+}
+
+// compareSecrets orders the secrets from the most severe, then from the
+// most certain.
+func compareSecrets(a, b Secret) int {
+	return cmp.Or(
+		rank(config.Severities, a.Severity)-rank(config.Severities, b.Severity),
+		rank(config.Confidences, a.Confidence)-rank(config.Confidences, b.Confidence),
+		strings.Compare(a.Key, b.Key),
+	)
+}
+
+// rank returns the index of v in levels, unknown values rank last.
+func rank(levels []string, v string) int {
+	if i := slices.Index(levels, v); i >= 0 {
+		return i
+	}
+	return len(levels)
+}
+
+// secrets returns every secret found so far.
+func (jq *jobQueue) secrets() []Secret {
+	txn := jq.db.Txn(false)
+	defer txn.Abort()
+	it, err := txn.Get("secret", "id")
+	if err != nil {
+		panic(err)
+	}
+	var secrets []Secret
+	for obj := it.Next(); obj != nil; obj = it.Next() {
+		secrets = append(secrets, obj.(Secret))
+	}
+	return secrets
 }
 
 func (jq *jobQueue) clearJobWaitGroup() {
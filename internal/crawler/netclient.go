@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
 )
 
 var (
@@ -12,9 +14,21 @@ var (
 	netClient *http.Client
 )
 
-func newNetClient() *http.Client {
+func newNetClient(c config.Config) (*http.Client, error) {
+	var err error
 	once.Do(func() {
+		var ps *proxySelector
+		ps, err = newProxySelector(c.Proxy)
+		if err != nil {
+			return
+		}
+		var jar http.CookieJar
+		jar, err = newCookieJar(c)
+		if err != nil {
+			return
+		}
 		var netTransport = &http.Transport{
+			Proxy: ps.proxy,
 			Dial: (&net.Dialer{
 				Timeout: 2 * time.Second,
 			}).Dial,
@@ -23,7 +37,8 @@ func newNetClient() *http.Client {
 		netClient = &http.Client{
 			Timeout:   time.Second * 2,
 			Transport: netTransport,
+			Jar:       jar,
 		}
 	})
-	return netClient
+	return netClient, err
 }
@@ -1,14 +1,12 @@
 package crawler
 
 import (
-	"bytes"
-	"fmt"
+	"errors"
 	"io"
+	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
-
-	"golang.org/x/net/html"
+	"time"
 )
 
 type Secret struct {
@@ -16,55 +14,90 @@ type Secret struct {
 	Hostname string
 	Key      string
 	Value    string
+	Severity string
+	Source   string // url the secret was found at, archive-url!inner/path for archive members and .git-url!commit:path for git blobs
+
+	Category   string // of the rule, one of config.Categories
+	CWE        string
+	Confidence string // one of config.Confidences
+
+	Fingerprint string // identifies the value once redacted, only set on output
+	WarcRecord  string // response record of the page the secret was found in
 }
 
 type pageNode struct {
-	targetUrl      string
-	foundUrls      []string
-	foundSecrets   []Secret
-	depth          int
-	payload        []byte
-	secretPatterns map[string]string
+	targetUrl    string
+	foundRefs    []ref // urls resolved against the target url
+	foundSecrets []Secret
+	depth        int
+	rules        *ruleSet
+	scanTime     time.Duration // spent running the rules while the body was processed
 }
 
-func newPageNode(targetUrl string, buf []byte) *pageNode {
-	commonPatterns := map[string]string{
-		"jwt":   `e[yw][A-Za-z0-9-_]+\.(?:e[yw][A-Za-z0-9-_]+)?\.[A-Za-z0-9-_]{2,}(?:(?:\.[A-Za-z0-9-_]{2,}){2})?`,
-		"email": `\b([\w\.-]{5,30})@[\w\.-]+\.([A-Za-z]{2,3})\b`,
-	}
+func newPageNode(targetUrl string, rules *ruleSet) *pageNode {
 	return &pageNode{
-		targetUrl:      targetUrl,
-		payload:        buf,
-		secretPatterns: commonPatterns,
-		foundUrls:      []string{},
-		foundSecrets:   []Secret{},
+		targetUrl:    targetUrl,
+		rules:        rules,
+		foundRefs:    []ref{},
+		foundSecrets: []Secret{},
 	}
 }
 
-func (node *pageNode) extractAndExtends(hostname string) error {
-	// look inside the current page
-	if _, err := node.extractUrls(); err != nil {
-		return err
+// extractAndExtends streams the body through the processor of its content
+// type, the secret scanner and the fingerprinter. buf is used as the
+// scanning window.
+func (node *pageNode) extractAndExtends(hostname string, body io.Reader, registry *processorRegistry, proc processor, buf []byte) (fingerprint, error) {
+	baseURL, err := url.Parse(node.targetUrl)
+	if err != nil {
+		return fingerprint{}, err
+	}
+	sink := newScanSink(node.targetUrl, registry, node.rules, buf)
+	fp := newFingerprinter()
+	tee := io.TeeReader(body, fp)
+
+	// look inside the current page, archives exceeding their limits still
+	// report what was found before the limit was hit
+	refs, procErr := proc.process(tee, sink)
+	if procErr != nil && !errors.Is(procErr, errArchiveLimit) {
+		return fingerprint{}, procErr
+	}
+	for _, r := range refs {
+		r.url = node.parseUrl(baseURL, r.url)
+		node.foundRefs = append(node.foundRefs, r)
 	}
+	// processors may stop before the end of the body
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return fingerprint{}, err
+	}
+
 	// and for secrets after
-	pStr := string(node.payload)
-	for key, pattern := range node.secretPatterns {
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			// TODO: should be strict or ignore?
-			continue
-		}
-		matches := re.FindAllStringSubmatch(pStr, -1)
-		for _, match := range matches {
-			if len(match) > 0 && match[0] != "" {
-				node.foundSecrets = append(
-					node.foundSecrets,
-					Secret{ID: fmt.Sprintf("%s:%s", hostname, match[0]), Hostname: hostname, Key: key, Value: match[0]},
-				)
-			}
+	sink.close()
+	for _, s := range append([]*scanSink{sink}, *sink.entries...) {
+		node.scanTime += s.busy
+	}
+	node.foundSecrets = append(node.foundSecrets, sinkSecrets(hostname, sink)...)
+	return fp.fingerprint(), procErr
+}
+
+// scanHeaders runs the rules over the header lines, findings are attributed
+// to target-url!part.
+func (node *pageNode) scanHeaders(hostname, part string, header http.Header) {
+	sink := newScanSink(node.targetUrl+"!"+part, nil, node.rules, make([]byte, 0, scanChunkSize+scanOverlap))
+	header.Write(sink)
+	sink.close()
+	node.foundSecrets = append(node.foundSecrets, sinkSecrets(hostname, sink)...)
+}
+
+// sinkSecrets returns the matches of a closed sink and of the files nested in
+// it, attributed to the sink they were found in.
+func sinkSecrets(hostname string, sink *scanSink) []Secret {
+	var secrets []Secret
+	for _, s := range append([]*scanSink{sink}, *sink.entries...) {
+		for _, m := range s.matches {
+			secrets = append(secrets, s.rules.secret(hostname, m.key, m.value, m.confidence, s.name))
 		}
 	}
-	return nil
+	return secrets
 }
 
 func (node *pageNode) parseUrl(baseUrl *url.URL, foundUrl string) string {
@@ -75,42 +108,3 @@ func (node *pageNode) parseUrl(baseUrl *url.URL, foundUrl string) string {
 	}
 	return baseUrl.ResolveReference(parsedHref).String()
 }
-
-func (node *pageNode) extractUrls() ([]string, error) {
-	baseURL, err := url.Parse(node.targetUrl)
-	if err != nil {
-		return node.foundUrls, err
-	}
-	tokenizer := html.NewTokenizer(bytes.NewReader(node.payload))
-	for {
-		switch tok := tokenizer.Next(); tok {
-		case html.ErrorToken:
-			if tokenizer.Err() == io.EOF {
-				return node.foundUrls, nil
-			}
-			return node.foundUrls, err
-		case html.StartTagToken, html.SelfClosingTagToken:
-			token := tokenizer.Token()
-			switch token.Data {
-			case "a":
-				for _, attr := range token.Attr {
-					if attr.Key == "href" {
-						node.foundUrls = append(node.foundUrls, node.parseUrl(baseURL, attr.Val))
-					}
-				}
-			case "link":
-				for _, attr := range token.Attr {
-					if attr.Key == "href" {
-						node.foundUrls = append(node.foundUrls, node.parseUrl(baseURL, attr.Val))
-					}
-				}
-			case "script":
-				for _, attr := range token.Attr {
-					if attr.Key == "src" && strings.HasSuffix(attr.Val, ".js") {
-						node.foundUrls = append(node.foundUrls, node.parseUrl(baseURL, attr.Val))
-					}
-				}
-			}
-		}
-	}
-}
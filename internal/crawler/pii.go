@@ -0,0 +1,306 @@
+package crawler
+
+import (
+	"fmt"
+	"maps"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// piiCountry holds the locale specific patterns of a country.
+type piiCountry struct {
+	phone string        // national phone number format
+	ids   []config.Rule // national ids
+}
+
+// piiCountries are the countries whose national ids and phone numbers can be
+// looked for.
+var piiCountries = map[string]piiCountry{
+	"us": {
+		phone: `(?:\([2-9]\d{2}\) ?|\b[2-9]\d{2}[-. ])[2-9]\d{2}[-. ]\d{4}\b`,
+		ids: []config.Rule{
+			{Name: "us_ssn", Pattern: `\b\d{3}-\d{2}-\d{4}\b`, Severity: "high", Keywords: []string{"ssn", "social security"}},
+		},
+	},
+	"gb": {
+		phone: `\b0(?:7\d{3} ?\d{6}|[1-3]\d(?: ?\d{4}){2}|[1-3]\d{2} ?\d{3} ?\d{4}|[1-3]\d{3} ?\d{5,6})\b`,
+		ids: []config.Rule{
+			{Name: "gb_nino", Pattern: `\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`, Severity: "high", Keywords: []string{"nino", "national insurance"}},
+		},
+	},
+	"fr": {
+		phone: `\b0[1-9](?:[ .-]?\d{2}){4}\b`,
+		ids: []config.Rule{
+			{Name: "fr_nir", Pattern: `\b[12] ?\d{2} ?(?:0[1-9]|1[0-2]|[2-9]\d) ?(?:\d{2}|2[AB]) ?\d{3} ?\d{3} ?\d{2}\b`, Severity: "high", Keywords: []string{"nir", "sécurité sociale", "securite sociale"}},
+		},
+	},
+	"nl": {
+		phone: `\b0(?:6[ -]?\d{8}|[1-57-9]\d{1,2}[ -]?\d{6,7})\b`,
+		ids: []config.Rule{
+			{Name: "nl_bsn", Pattern: `\b\d{9}\b`, Severity: "high", Keywords: []string{"bsn", "burgerservicenummer"}},
+		},
+	},
+	"es": {
+		phone: `\b[6-9]\d{2}(?:(?:[ .-]\d{3}){2}|(?:[ .-]\d{2}){3})\b`,
+		ids: []config.Rule{
+			{Name: "es_dni", Pattern: `\b(?:\d{8}|[XYZ]-?\d{7})-?[A-Z]\b`, Severity: "high", Keywords: []string{"dni", "nie", "nif"}},
+		},
+	},
+	"in": {
+		phone: `\b[6-9]\d{4}[ -]?\d{5}\b`,
+		ids: []config.Rule{
+			{Name: "in_aadhaar", Pattern: `\b[2-9]\d{3} ?\d{4} ?\d{4}\b`, Severity: "high", Keywords: []string{"aadhaar", "uid"}},
+		},
+	},
+}
+
+const (
+	// e164 matches international phone numbers, digits may be separated.
+	e164 = `\+[1-9](?:[ .-]?\d){7,14}\b`
+	ipv4 = `\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`
+	// ipv6 is loose, the matches are validated by validIP
+	ipv6 = `\b[0-9a-fA-F]{1,4}(?::[0-9a-fA-F]{0,4}){2,7}`
+)
+
+// piiRules returns the rules of the PII pack, the national ids and phone
+// formats are the ones of the configured countries.
+func piiRules(p *config.Pii) ([]config.Rule, error) {
+	if p == nil || p.Enabled == nil || !*p.Enabled {
+		return nil, nil
+	}
+	countries := p.Countries
+	if len(countries) == 0 {
+		countries = slices.Sorted(maps.Keys(piiCountries))
+	}
+	phones := []string{e164}
+	var ids []config.Rule
+	for _, name := range countries {
+		country, ok := piiCountries[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported pii country %q, countries are us, gb, fr, nl, es and in", name)
+		}
+		phones = append(phones, "(?:"+country.phone+")")
+		ids = append(ids, country.ids...)
+	}
+	rules := []config.Rule{
+		{Name: "phone_number", Pattern: strings.Join(phones, "|"), Severity: "low", Keywords: []string{"tel", "phone", "mobile", "call", "fax"}},
+		{Name: "iban", Pattern: `\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`, Severity: "medium"},
+		{Name: "credit_card", Pattern: `\b(?:\d[ -]?){12,18}\d\b`, Severity: "high"},
+		{Name: "ip_address", Pattern: ipv4 + "|" + ipv6, Severity: "low", Keywords: []string{"ip", "addr", "host", "server"}},
+	}
+	rules = append(rules, ids...)
+	for i := range rules {
+		rules[i].Category = "pii"
+	}
+	return rules, nil
+}
+
+// ibanLengths are the lengths of the IBANs of every country using them.
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22, "BH": 22, "BR": 29,
+	"CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22, "DK": 18, "DO": 28, "EE": 20, "EG": 29, "ES": 24,
+	"FI": 18, "FO": 18, "FR": 27, "GB": 22, "GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28, "HR": 21,
+	"HU": 28, "IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30, "KZ": 20, "LB": 28,
+	"LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MD": 24, "ME": 22, "MK": 19, "MR": 27,
+	"MT": 31, "MU": 30, "NL": 18, "NO": 15, "PK": 24, "PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24,
+	"RS": 22, "SA": 24, "SC": 31, "SE": 24, "SI": 19, "SK": 24, "SM": 27, "ST": 25, "SV": 28, "TL": 23,
+	"TN": 24, "TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20,
+}
+
+// validIBAN checks the length of the IBAN for its country and its ISO 7064
+// mod 97 checksum.
+func validIBAN(value string) bool {
+	iban := strings.ReplaceAll(value, " ", "")
+	if len(iban) < 4 {
+		return false
+	}
+	if n, ok := ibanLengths[iban[:2]]; !ok || len(iban) != n {
+		return false
+	}
+	// the country and check digits are moved to the end, letters count as
+	// 10 to 35
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validCard checks the prefix of the number against the issuers and its
+// Luhn checksum.
+func validCard(value string) bool {
+	number := stripSeparators(value)
+	if !allDigits(number) || len(number) < 13 || len(number) > 19 {
+		return false
+	}
+	prefix, _ := strconv.Atoi(number[:4])
+	switch {
+	case number[0] == '4': // visa
+	case prefix >= 5100 && prefix <= 5599, prefix >= 2221 && prefix <= 2720: // mastercard
+	case prefix/100 == 34, prefix/100 == 37: // amex
+	case prefix == 6011, prefix/100 == 65, prefix/100 == 62: // discover, unionpay
+	case prefix/100 == 35: // jcb
+	case prefix/100 == 30, prefix/100 == 36, prefix/100 == 38: // diners
+	default:
+		return false
+	}
+	return luhn(number)
+}
+
+func luhn(number string) bool {
+	sum := 0
+	for i := range len(number) {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validIP rejects the loopback and unspecified addresses, which aren't
+// anyone's, and the short ipv6 lookalikes such as a::b.
+func validIP(value string) bool {
+	addr, err := netip.ParseAddr(value)
+	if err != nil || addr.IsLoopback() || addr.IsUnspecified() {
+		return false
+	}
+	if addr.Is6() {
+		var groups int
+		for _, g := range strings.Split(value, ":") {
+			if len(g) > 0 {
+				groups++
+			}
+		}
+		return groups >= 3
+	}
+	return true
+}
+
+// validSSN rejects the area, group and serial numbers never assigned.
+func validSSN(value string) bool {
+	if len(value) != 11 {
+		return false
+	}
+	area, group, serial := value[:3], value[4:6], value[7:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validNINO rejects the prefixes never assigned.
+func validNINO(value string) bool {
+	if len(value) < 2 {
+		return false
+	}
+	switch value[:2] {
+	case "BG", "GB", "NK", "KN", "TN", "NT", "ZZ":
+		return false
+	}
+	return true
+}
+
+// validNIR checks the key of a French social security number, the last two
+// digits, Corsican departments 2A and 2B count as 19 and 18.
+func validNIR(value string) bool {
+	nir := strings.ReplaceAll(value, " ", "")
+	if len(nir) != 15 {
+		return false
+	}
+	body, key := nir[:13], nir[13:]
+	offset := int64(0)
+	switch body[5:7] {
+	case "2A":
+		body, offset = body[:5]+"19"+body[7:], 1000000
+	case "2B":
+		body, offset = body[:5]+"18"+body[7:], 2000000
+	}
+	n, err := strconv.ParseInt(body, 10, 64)
+	if err != nil {
+		return false
+	}
+	k, _ := strconv.ParseInt(key, 10, 64)
+	return 97-(n-offset)%97 == k
+}
+
+// validBSN applies the eleven test to a Dutch citizen service number.
+func validBSN(value string) bool {
+	if !allDigits(value) || len(value) != 9 {
+		return false
+	}
+	sum := 0
+	for i := range 8 {
+		sum += int(value[i]-'0') * (9 - i)
+	}
+	sum -= int(value[8] - '0')
+	return sum%11 == 0 && value != "000000000"
+}
+
+// validDNI checks the control letter of a Spanish DNI or NIE.
+func validDNI(value string) bool {
+	id := strings.ReplaceAll(value, "-", "")
+	if len(id) < 2 {
+		return false
+	}
+	id = strings.NewReplacer("X", "0", "Y", "1", "Z", "2").Replace(id[:1]) + id[1:]
+	n, err := strconv.Atoi(id[:len(id)-1])
+	if err != nil {
+		return false
+	}
+	return "TRWAGMYFPDXBNJZSQVHLCKE"[n%23] == id[len(id)-1]
+}
+
+// verhoeff tables, the multiplication table of the dihedral group D5 and
+// the permutation applied to every position.
+var (
+	verhoeffD = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, {1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6}, {3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8}, {5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2}, {7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4}, {9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, {1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2}, {8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0}, {4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5}, {7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// validAadhaar checks the Verhoeff checksum of an Indian Aadhaar number.
+func validAadhaar(value string) bool {
+	number := stripSeparators(value)
+	if !allDigits(number) || len(number) != 12 {
+		return false
+	}
+	c := 0
+	for i := range len(number) {
+		c = verhoeffD[c][verhoeffP[i%8][int(number[len(number)-1-i]-'0')]]
+	}
+	return c == 0
+}
+
+func stripSeparators(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+func allDigits(s string) bool {
+	return len(strings.TrimLeft(s, "0123456789")) == 0
+}
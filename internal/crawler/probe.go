@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math/bits"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+const (
+	exposedPathRule = "exposed_path"
+	// simhash bits that may differ between a probe and the not found baseline
+	// for the probe to still be considered a soft 404.
+	softNotFoundDistance = 10
+)
+
+var defaultProbePaths = []string{
+	"/.git/HEAD", "/.git/config", "/.env", "/.env.local", "/.env.production",
+	"/config.json", "/backup.zip", "/backup.tar.gz", "/.DS_Store", "/server-status",
+	"/.svn/entries", "/.htpasswd", "/.aws/credentials", "/docker-compose.yml", "/phpinfo.php",
+}
+
+// baseline is the response of a path that can't exist on the host, probes
+// looking like it are soft 404s.
+type baseline struct {
+	once   sync.Once
+	status int
+	fp     fingerprint
+}
+
+// prober requests well known sensitive paths once on every discovered host.
+type prober struct {
+	enabled   bool
+	paths     []string
+	origins   sync.Map // scheme://host probed so far
+	baselines sync.Map // scheme://host -> *baseline
+}
+
+func newProber(p *config.Probe) (*prober, error) {
+	pr := &prober{}
+	if p == nil || p.Enabled == nil || !*p.Enabled {
+		return pr, nil
+	}
+	pr.enabled = true
+	pr.paths = p.Paths
+	if len(pr.paths) == 0 {
+		pr.paths = defaultProbePaths
+	}
+	if len(p.Wordlist) > 0 {
+		paths, err := readWordlist(p.Wordlist)
+		if err != nil {
+			return nil, err
+		}
+		pr.paths = append(append([]string{}, pr.paths...), paths...)
+	}
+	return pr, nil
+}
+
+func readWordlist(src string) ([]string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		p := strings.TrimSpace(scanner.Text())
+		if len(p) == 0 || strings.HasPrefix(p, "#") {
+			continue
+		}
+		paths = append(paths, "/"+strings.TrimPrefix(p, "/"))
+	}
+	return paths, scanner.Err()
+}
+
+// discover returns the probe urls of the host of u the first time the host
+// is seen.
+func (p *prober) discover(u string) []string {
+	if !p.enabled {
+		return nil
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || len(parsed.Host) == 0 {
+		return nil
+	}
+	origin := parsed.Scheme + "://" + parsed.Host
+	if _, loaded := p.origins.LoadOrStore(origin, struct{}{}); loaded {
+		return nil
+	}
+	urls := make([]string, 0, len(p.paths))
+	for _, path := range p.paths {
+		urls = append(urls, origin+path)
+	}
+	return urls
+}
+
+// softNotFound reports whether the probe response is the host's way of
+// saying not found, such as a redirect to the home page or a custom not found
+// page served with a 200.
+func (p *prober) softNotFound(ctx context.Context, c *Crawler, probeUrl string, resp *response, fp fingerprint) bool {
+	if resp.Request.URL.String() != probeUrl {
+		return true // redirected elsewhere
+	}
+	parsed, err := url.Parse(probeUrl)
+	if err != nil {
+		return true
+	}
+	origin := parsed.Scheme + "://" + parsed.Host
+	v, _ := p.baselines.LoadOrStore(origin, &baseline{})
+	b := v.(*baseline)
+	b.once.Do(func() {
+		b.status, b.fp = c.fetchBaseline(ctx, origin+"/"+randomHex(12)+"/"+randomHex(6)+".html")
+	})
+
+	if b.status != resp.StatusCode {
+		return false
+	}
+	if b.fp.sum == fp.sum {
+		return true
+	}
+	return b.fp.features >= minSimhashFeatures && fp.features >= minSimhashFeatures &&
+		bits.OnesCount64(b.fp.simhash^fp.simhash) <= softNotFoundDistance
+}
+
+// fetchBaseline requests a path that can't exist and returns its status and
+// fingerprint, a status of 0 means the request failed.
+func (c *Crawler) fetchBaseline(ctx context.Context, u string) (int, fingerprint) {
+	resp, err := c.send(ctx, u)
+	if err != nil {
+		return 0, fingerprint{}
+	}
+	defer resp.Close()
+	fp := newFingerprinter()
+	io.Copy(fp, resp.body)
+	return resp.StatusCode, fp.fingerprint()
+}
+
+// drainProcessor reads probe hits whose content type can't be processed, the
+// hit itself is the finding.
+type drainProcessor struct{}
+
+func (p *drainProcessor) process(body io.Reader, sink *scanSink) ([]ref, error) {
+	_, err := io.Copy(io.Discard, body)
+	return nil, err
+}
@@ -0,0 +1,445 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"golang.org/x/net/html"
+)
+
+// processor extracts the references of a body and decides what the secret
+// rules run against: it writes the text to scan to the sink while reading
+// the body.
+type processor interface {
+	process(body io.Reader, sink *scanSink) ([]ref, error)
+}
+
+// ref is a reference found in a body, tag tells what it was found in: the
+// html element or the kind of document.
+type ref struct {
+	url  string
+	tag  string
+	text string // anchor text
+}
+
+// scanSink receives the text the secret rules run against. Processors of
+// containers such as archives open a new sink for every file they hold, so
+// that findings are attributed to the file they were found in.
+type scanSink struct {
+	*streamScanner
+	name     string
+	depth    int
+	guard    *bombGuard // shared by the members of an archive
+	registry *processorRegistry
+	entries  *[]*scanSink
+	matches  []match
+}
+
+func newScanSink(name string, registry *processorRegistry, rules *ruleSet, buf []byte) *scanSink {
+	return &scanSink{
+		streamScanner: newStreamScanner(rules, buf),
+		name:          name,
+		registry:      registry,
+		entries:       &[]*scanSink{},
+	}
+}
+
+// entry opens the sink of a file nested in the body, named
+// parent!inner/path.
+func (s *scanSink) entry(name string) *scanSink {
+	child := &scanSink{
+		streamScanner: newStreamScanner(s.rules, make([]byte, 0, scanChunkSize+scanOverlap)),
+		name:          s.name + "!" + name,
+		depth:         s.depth + 1,
+		registry:      s.registry,
+		entries:       s.entries,
+	}
+	*s.entries = append(*s.entries, child)
+	return child
+}
+
+// close scans what is left and releases the scanning window.
+func (s *scanSink) close() {
+	s.matches = s.flush()
+	s.window = nil
+}
+
+type processorEntry struct {
+	name  string
+	types []string // content types handled, wildcards allowed
+	proc  processor
+}
+
+// processors are matched in order, the binary processor catches everything
+// the other processors don't handle.
+var processors = []processorEntry{
+	{name: "html", types: []string{"text/html", "application/xhtml+xml"}, proc: &htmlProcessor{}},
+	{name: "javascript", types: []string{"application/javascript", "application/x-javascript", "application/ecmascript", "text/javascript", "text/ecmascript"}, proc: &regexProcessor{tag: "script", patterns: jsLinkPatterns}},
+	{name: "css", types: []string{"text/css"}, proc: &regexProcessor{tag: "css", patterns: cssLinkPatterns}},
+	{name: "json", types: []string{"application/json", "application/*+json", "text/json"}, proc: &jsonProcessor{}},
+	{name: "xml", types: []string{"application/xml", "text/xml", "application/*+xml", "image/svg+xml"}, proc: &xmlProcessor{}},
+	{name: "text", types: []string{"text/*", "application/x-www-form-urlencoded", "application/x-sh", "application/x-httpd-php", "application/sql"}, proc: &regexProcessor{tag: "text", patterns: textLinkPatterns}},
+	{name: "archive", types: archiveTypes, proc: &archiveProcessor{}},
+	{name: "binary", types: []string{"*"}, proc: &binaryProcessor{}},
+}
+
+var archiveTypes = []string{
+	"application/zip", "application/x-zip-compressed", "application/gzip", "application/x-gzip",
+	"application/x-tar", "application/x-gtar", "application/x-bzip2", "application/x-bzip",
+	"application/x-compressed-tar", "application/java-archive",
+}
+
+type processorRegistry struct {
+	enabled map[string]bool
+	archive archiveLimits
+}
+
+// newProcessorRegistry enables every processor unless disabled in the
+// config, except for the binary processor which follows scanBinary.
+func newProcessorRegistry(cfg map[string]bool, scanBinary bool, archives *config.Archives) (*processorRegistry, error) {
+	pr := &processorRegistry{enabled: map[string]bool{}, archive: newArchiveLimits(archives)}
+	for _, p := range processors {
+		pr.enabled[p.name] = p.name != "binary" || scanBinary
+	}
+	for name, enabled := range cfg {
+		if _, ok := pr.enabled[name]; !ok {
+			return nil, fmt.Errorf("unknown processor %q", name)
+		}
+		pr.enabled[name] = enabled
+	}
+	return pr, nil
+}
+
+// lookup returns the processor for the response, or nil when the processor
+// for its type is disabled.
+func (pr *processorRegistry) lookup(contentType string, peek []byte, u string) (string, processor) {
+	contentType = sniffType(contentType, peek, u)
+	for _, p := range processors {
+		for _, t := range p.types {
+			if !matchGlob(t, contentType) {
+				continue
+			}
+			if !pr.enabled[p.name] {
+				return p.name, nil
+			}
+			return p.name, p.proc
+		}
+	}
+	return "", nil
+}
+
+// sniffType refines generic content types, servers commonly send json, js
+// or html as text/plain or application/octet-stream.
+func sniffType(contentType string, peek []byte, u string) string {
+	switch contentType {
+	case "", "text/plain", "application/octet-stream":
+	default:
+		return contentType
+	}
+	if parsed, err := url.Parse(u); err == nil {
+		switch strings.ToLower(path.Ext(parsed.Path)) {
+		case ".js", ".mjs":
+			return "application/javascript"
+		case ".css":
+			return "text/css"
+		case ".json", ".map":
+			return "application/json"
+		}
+	}
+	if archive := contentTypeOf(u, peek); slices.Contains(archiveTypes, archive) {
+		return archive
+	}
+	trimmed := bytes.TrimSpace(peek)
+	switch {
+	case len(trimmed) == 0:
+		return contentType
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed):
+		return "application/json"
+	case (trimmed[0] == '{' || trimmed[0] == '[') && len(peek) == peekSize:
+		return "application/json" // truncated peek of a larger document
+	case bytes.HasPrefix(trimmed, []byte("<?xml")):
+		return "application/xml"
+	}
+	lower := bytes.ToLower(trimmed[:min(len(trimmed), 512)])
+	if bytes.HasPrefix(lower, []byte("<!doctype html")) || bytes.HasPrefix(lower, []byte("<html")) {
+		return "text/html"
+	}
+	// files without a known extension, such as .env, are served as binary
+	if sniffed := sniffContentType(peek); strings.HasPrefix(sniffed, "text/") {
+		return sniffed
+	}
+	return contentType
+}
+
+type htmlProcessor struct{}
+
+func (p *htmlProcessor) process(body io.Reader, sink *scanSink) ([]ref, error) {
+	var (
+		refs   []ref
+		anchor = -1 // ref of the anchor whose text is being read
+		text   strings.Builder
+	)
+	tokenizer := html.NewTokenizer(io.TeeReader(body, sink))
+	for {
+		switch tok := tokenizer.Next(); tok {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return refs, nil
+			}
+			return refs, tokenizer.Err()
+		case html.TextToken:
+			if anchor >= 0 && text.Len() < maxAnchorText {
+				text.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "a" && anchor >= 0 {
+				refs[anchor].text = anchorText(text.String())
+				anchor = -1
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "a", "link":
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						refs = append(refs, ref{url: attr.Val, tag: token.Data})
+						if token.Data == "a" && tok == html.StartTagToken {
+							anchor = len(refs) - 1
+							text.Reset()
+						}
+					}
+				}
+			case "script":
+				for _, attr := range token.Attr {
+					if attr.Key == "src" && strings.HasSuffix(attr.Val, ".js") {
+						refs = append(refs, ref{url: attr.Val, tag: token.Data})
+					}
+				}
+			}
+		}
+	}
+}
+
+const maxAnchorText = 256
+
+// anchorText collapses the white space of the text of an anchor.
+func anchorText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxAnchorText {
+		s = strings.ToValidUTF8(s[:maxAnchorText], "")
+	}
+	return s
+}
+
+var (
+	jsLinkPatterns = []*regexp.Regexp{
+		regexp.MustCompile("[\"'`](https?://[^\"'`\\s<>]+|/[A-Za-z0-9_\\-.~/%?=&;:@+]+)[\"'`]"),
+	}
+	cssLinkPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)['"]?\s*\)`),
+		regexp.MustCompile(`@import\s+['"]([^'"]+)['"]`),
+	}
+	textLinkPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(https?://[^\s"'<>]+)`),
+	}
+)
+
+// regexProcessor scans the raw body, references are the first group of its
+// patterns.
+type regexProcessor struct {
+	tag      string
+	patterns []*regexp.Regexp
+}
+
+func (p *regexProcessor) process(body io.Reader, sink *scanSink) ([]ref, error) {
+	rs := &ruleSet{}
+	for _, re := range p.patterns {
+		rs.rules = append(rs.rules, rule{name: "link", re: re})
+	}
+	links := newStreamScanner(rs, make([]byte, 0, scanChunkSize+scanOverlap))
+	if _, err := io.Copy(io.MultiWriter(sink, links), body); err != nil {
+		return nil, err
+	}
+
+	var refs []ref
+	for _, m := range links.flush() {
+		for _, re := range p.patterns {
+			if sub := re.FindStringSubmatch(m.value); len(sub) > 1 {
+				if u := sub[1]; u != "/" && !strings.HasPrefix(u, "//") {
+					refs = append(refs, ref{url: u, tag: p.tag})
+				}
+				break
+			}
+		}
+	}
+	return refs, nil
+}
+
+// jsonProcessor scans the raw document and walks it structurally, the
+// scalar values are scanned again decoded and prefixed with their key so
+// rules can match on key names and escaped values.
+type jsonProcessor struct{}
+
+func (p *jsonProcessor) process(body io.Reader, sink *scanSink) ([]ref, error) {
+	var (
+		refs    []ref
+		stack   []bool // whether each enclosing container is an object
+		key     string
+		isKey   bool
+		decoded bytes.Buffer // scanned after the raw document
+	)
+	flush := func() error {
+		if decoded.Len() == 0 {
+			return nil
+		}
+		if _, err := io.WriteString(sink, "\n"); err != nil {
+			return err
+		}
+		_, err := decoded.WriteTo(sink)
+		return err
+	}
+	dec := json.NewDecoder(io.TeeReader(body, sink))
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return refs, flush()
+		}
+		if err != nil {
+			// not quite json, scan the rest as it is
+			if _, err := io.Copy(sink, body); err != nil {
+				return refs, err
+			}
+			return refs, flush()
+		}
+
+		inObject := len(stack) > 0 && stack[len(stack)-1]
+		switch v := tok.(type) {
+		case json.Delim:
+			switch v {
+			case '{':
+				stack = append(stack, true)
+				isKey = true
+			case '[':
+				stack = append(stack, false)
+			default:
+				stack = stack[:len(stack)-1]
+				isKey = len(stack) > 0 && stack[len(stack)-1]
+			}
+			continue
+		case string:
+			if inObject && isKey {
+				key, isKey = v, false
+				continue
+			}
+			if looksLikeUrl(v) {
+				refs = append(refs, ref{url: v, tag: "json"})
+			}
+			fmt.Fprintf(&decoded, "%s: %s\n", key, v)
+		case nil:
+		default:
+			fmt.Fprintf(&decoded, "%s: %v\n", key, v)
+		}
+		isKey = inObject
+	}
+}
+
+func looksLikeUrl(s string) bool {
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return true
+	}
+	return len(s) > 1 && s[0] == '/' && s[1] != '/' && !strings.ContainsAny(s, " \t\n")
+}
+
+// xmlProcessor scans the raw document and follows sitemap locations, feed
+// links and the usual link attributes.
+type xmlProcessor struct{}
+
+func (p *xmlProcessor) process(body io.Reader, sink *scanSink) ([]ref, error) {
+	var refs []ref
+	dec := xml.NewDecoder(io.TeeReader(body, sink))
+	dec.Strict = false
+	var current string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return refs, nil
+		}
+		if err != nil {
+			// keep scanning what's left even if the document is malformed
+			_, cerr := io.Copy(sink, body)
+			return refs, cerr
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			current = t.Name.Local
+			for _, attr := range t.Attr {
+				switch attr.Name.Local {
+				case "href", "src":
+					refs = append(refs, ref{url: attr.Value, tag: t.Name.Local})
+				}
+			}
+		case xml.CharData:
+			if current == "loc" || current == "link" {
+				if u := strings.TrimSpace(string(t)); len(u) > 0 {
+					refs = append(refs, ref{url: u, tag: current})
+				}
+			}
+		case xml.EndElement:
+			current = ""
+		}
+	}
+}
+
+const minPrintableRun = 6
+
+// binaryProcessor scans runs of printable characters, the same way strings(1)
+// does.
+type binaryProcessor struct{}
+
+func (p *binaryProcessor) process(body io.Reader, sink *scanSink) ([]ref, error) {
+	var (
+		run    = make([]byte, 0, minPrintableRun)
+		runLen int
+		buf    = make([]byte, 32*1024)
+		out    = make([]byte, 0, 32*1024)
+	)
+	for {
+		n, err := body.Read(buf)
+		out = out[:0]
+		for _, b := range buf[:n] {
+			if b >= 0x20 && b < 0x7f || b == '\t' {
+				runLen++
+				switch {
+				case runLen > minPrintableRun:
+					out = append(out, b)
+				case runLen == minPrintableRun:
+					out = append(append(out, run...), b)
+				default:
+					run = append(run, b)
+				}
+				continue
+			}
+			if runLen >= minPrintableRun {
+				out = append(out, '\n')
+			}
+			run, runLen = run[:0], 0
+		}
+		sink.Write(out)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
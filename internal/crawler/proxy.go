@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ganbarodigital/go_glob"
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+const directProxy = "direct"
+
+type proxyRule struct {
+	domain string
+	proxy  *url.URL // nil means direct connection
+}
+
+type proxySelector struct {
+	rules   []proxyRule
+	pool    []*url.URL
+	noProxy []string
+	env     bool
+	next    uint64
+}
+
+func newProxySelector(p *config.Proxy) (*proxySelector, error) {
+	ps := &proxySelector{env: true}
+	if p == nil {
+		return ps, nil
+	}
+	ps.env = !p.DisableEnv
+	ps.noProxy = p.NoProxy
+
+	for _, r := range p.Rules {
+		rule := proxyRule{domain: r.Domain}
+		if !strings.EqualFold(r.URL, directProxy) {
+			u, err := parseProxyUrl(r.URL, p.Username, p.Password)
+			if err != nil {
+				return nil, err
+			}
+			rule.proxy = u
+		}
+		ps.rules = append(ps.rules, rule)
+	}
+
+	raw := p.Pool
+	if len(p.URL) > 0 {
+		raw = append([]string{p.URL}, raw...)
+	}
+	for _, r := range raw {
+		u, err := parseProxyUrl(r, p.Username, p.Password)
+		if err != nil {
+			return nil, err
+		}
+		ps.pool = append(ps.pool, u)
+	}
+	return ps, nil
+}
+
+// parseProxyUrl validates the proxy url and attaches the shared credentials
+// when the url itself carries none.
+func parseProxyUrl(raw, username, password string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.User == nil && len(username) > 0 {
+		u.User = url.UserPassword(username, password)
+	}
+	return u, nil
+}
+
+// proxy implements http.Transport.Proxy. Per domain rules take precedence,
+// followed by the configured no proxy list, the proxy pool and finally the
+// proxy environment variables.
+func (ps *proxySelector) proxy(req *http.Request) (*url.URL, error) {
+	hostname := req.URL.Hostname()
+	for _, r := range ps.rules {
+		if matchGlob(r.domain, hostname) {
+			return r.proxy, nil
+		}
+	}
+	for _, pattern := range ps.noProxy {
+		if matchGlob(pattern, hostname) {
+			return nil, nil
+		}
+	}
+	if len(ps.pool) > 0 {
+		n := atomic.AddUint64(&ps.next, 1) - 1
+		return ps.pool[n%uint64(len(ps.pool))], nil
+	}
+	if ps.env {
+		return http.ProxyFromEnvironment(req)
+	}
+	return nil, nil
+}
+
+func matchGlob(pattern, s string) bool {
+	ok, err := glob.NewGlob(pattern).Match(s)
+	return err == nil && ok
+}
+
+// checkGlob returns the error of an invalid glob pattern, matchGlob never
+// matches them.
+func checkGlob(pattern string) error {
+	_, err := glob.NewGlob(pattern).Match("")
+	return err
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/store"
+)
+
+// Rescan runs the current rules over the responses archived in WARC or HAR
+// files, nothing is requested. Findings are written to the same outputs as
+// the ones of a crawl.
+func (c *Crawler) Rescan(archives []string) error {
+	if len(archives) == 0 {
+		return errors.New("Please provide at least 1 archive to rescan")
+	}
+	started := time.Now()
+	defer c.notifiers.Close()
+	defer c.store.Close()
+	if err := c.store.BeginRun(started, archives); err != nil {
+		return err
+	}
+	buf := make([]byte, 0, scanChunkSize+scanOverlap)
+	for _, src := range archives {
+		read, har := readWarc, strings.EqualFold(filepath.Ext(src), ".har")
+		if har {
+			read = readHar
+		}
+		err := read(src, func(resp *http.Response, record string) error {
+			if resp.StatusCode == http.StatusOK {
+				c.jq.crawled++
+			}
+			pNode := c.scanArchived(newResponse(resp, c.limits), record, buf)
+			// the headers of HAR entries are scanned as well, like when
+			// the capture is imported
+			if har {
+				if pNode == nil {
+					pNode = newPageNode(resp.Request.URL.String(), c.rules)
+				}
+				hostname := resp.Request.URL.Hostname()
+				pNode.scanHeaders(hostname, "request-headers", resp.Request.Header)
+				pNode.scanHeaders(hostname, "response-headers", resp.Header)
+			}
+			if pNode != nil {
+				c.jq.enqueue(nil, pNode.foundSecrets)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", src, err)
+		}
+	}
+	c.output(started, archives)
+	c.logger.Info().Msg(fmt.Sprintf("%d archived pages rescanned", c.jq.crawled))
+	return nil
+}
+
+// scanArchived scans an archived response the way execute scans a fetched
+// one, it returns nil when the response isn't scanned.
+func (c *Crawler) scanArchived(resp *response, record string, buf []byte) *pageNode {
+	defer resp.Close()
+	u := resp.Request.URL
+	target := u.String()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	page := store.Page{Url: target, Status: resp.StatusCode, ContentType: resp.contentType}
+	defer func() {
+		if err := c.store.AddPage(page); err != nil {
+			c.logger.Debug().Msg(err.Error())
+		}
+	}()
+	name, proc := c.processors.lookup(resp.contentType, resp.peek, target)
+	if proc == nil {
+		c.logs.parse.Debug().Msg(fmt.Sprintf("Skipping %s, %s processor is disabled for %s", target, name, resp.contentType))
+		return nil
+	}
+
+	hostname := u.Hostname()
+	pNode := newPageNode(target, c.rules)
+	fp, err := pNode.extractAndExtends(hostname, resp.body, c.processors, proc, buf)
+	if errors.Is(err, errArchiveLimit) {
+		c.logs.parse.Debug().Err(err).Msg(fmt.Sprintf("Stopped inspecting archive %s", target))
+	} else if err != nil {
+		c.logs.parse.Debug().Err(err).Msg(fmt.Sprintf("Error while processing %s content of %s\n", name, target))
+		return nil
+	}
+	page.Size, page.Hash = fp.size, hex.EncodeToString(fp.sum[:])
+	for i := range pNode.foundSecrets {
+		pNode.foundSecrets[i].WarcRecord = record
+	}
+	if dup, kind, newHost := c.dedup.seen(target, fp); dup {
+		c.logs.queue.Debug().Msg(fmt.Sprintf("Skipping %s, %s duplicate of an already scanned page", target, kind))
+		if kind != "near" && !newHost {
+			return nil
+		}
+		// near duplicates and duplicates on another host keep their
+		// secrets, their links aren't followed
+		pNode.foundRefs = nil
+	}
+	return pNode
+}
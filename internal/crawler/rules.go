@@ -0,0 +1,214 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+var commonPatterns = []config.Rule{
+	{Name: "jwt", Pattern: `e[yw][A-Za-z0-9-_]+\.(?:e[yw][A-Za-z0-9-_]+)?\.[A-Za-z0-9-_]{2,}(?:(?:\.[A-Za-z0-9-_]{2,}){2})?`, Severity: "high", Category: "credential"},
+	{Name: "email", Pattern: `\b([\w\.-]{5,30})@[\w\.-]+\.([A-Za-z]{2,3})\b`, Severity: "low", Category: "pii", Keywords: []string{"mail", "contact"}},
+}
+
+// validators confirm the matches of the built-in rules, the matches failing
+// validation are dropped.
+var validators = map[string]func(value string) bool{
+	"jwt":         validJWT,
+	"iban":        validIBAN,
+	"credit_card": validCard,
+	"ip_address":  validIP,
+	"us_ssn":      validSSN,
+	"gb_nino":     validNINO,
+	"fr_nir":      validNIR,
+	"nl_bsn":      validBSN,
+	"es_dni":      validDNI,
+	"in_aadhaar":  validAadhaar,
+}
+
+// findingRules describe the findings that don't come from a pattern.
+var findingRules = []config.Rule{
+	{Name: exposedPathRule, Severity: "high", Category: "endpoint", CWE: "CWE-538"},
+}
+
+// publicRules find values that aren't secret, such as urls, they are never
+// redacted.
+var publicRules = map[string]bool{
+	exposedPathRule: true,
+}
+
+// categoryCWEs are the CWEs of the rules that don't set one.
+var categoryCWEs = map[string]string{
+	"credential": "CWE-798",
+	"pii":        "CWE-359",
+	"infra":      "CWE-200",
+	"endpoint":   "CWE-538",
+}
+
+// credentialKeywords raise the confidence of the credential matches they
+// precede, for the rules that don't set their own keywords.
+var credentialKeywords = []string{"key", "secret", "token", "passw", "auth", "credential", "bearer"}
+
+const (
+	// keywordDistance is how far before a match its keywords are looked for.
+	keywordDistance = 32
+	// highEntropy is the entropy, in bits per character, above which a
+	// credential looks random enough to be a real one.
+	highEntropy = 3.5
+)
+
+type rule struct {
+	name     string
+	re       *regexp.Regexp
+	category string
+	validate func(value string) bool
+	keywords [][]byte // lower cased
+}
+
+// ruleInfo classifies the findings of a rule.
+type ruleInfo struct {
+	severity string
+	category string
+	cwe      string
+}
+
+// ruleSet holds the compiled secret patterns, the common patterns followed
+// by the rules of the config.
+type ruleSet struct {
+	rules []rule
+	infos map[string]ruleInfo // rule name -> classification
+}
+
+func newRuleSet(rules []config.Rule) (*ruleSet, error) {
+	rs := &ruleSet{infos: map[string]ruleInfo{}}
+	for _, r := range findingRules {
+		rs.infos[r.Name] = ruleInfo{severity: r.Severity, category: r.Category, cwe: r.CWE}
+	}
+	for _, r := range append(append([]config.Rule{}, commonPatterns...), rules...) {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for rule %s: %w", r.Name, err)
+		}
+		info := ruleInfo{severity: r.Severity, category: r.Category, cwe: r.CWE}
+		if len(info.severity) == 0 {
+			info.severity = config.DEFAULT_SEVERITY
+		} else if !slices.Contains(config.Severities, info.severity) {
+			return nil, fmt.Errorf("invalid severity %q for rule %s", r.Severity, r.Name)
+		}
+		if len(info.category) == 0 {
+			info.category = config.DEFAULT_CATEGORY
+		} else if !slices.Contains(config.Categories, info.category) {
+			return nil, fmt.Errorf("invalid category %q for rule %s, categories are %s", r.Category, r.Name, strings.Join(config.Categories, ", "))
+		}
+		if len(info.cwe) == 0 {
+			info.cwe = categoryCWEs[info.category]
+		}
+		keywords := r.Keywords
+		if len(keywords) == 0 && info.category == "credential" {
+			keywords = credentialKeywords
+		}
+		compiled := rule{name: r.Name, re: re, category: info.category, validate: validators[r.Name]}
+		for _, k := range keywords {
+			compiled.keywords = append(compiled.keywords, bytes.ToLower([]byte(k)))
+		}
+		rs.rules = append(rs.rules, compiled)
+		rs.infos[r.Name] = info
+	}
+	return rs, nil
+}
+
+// info returns the classification of the findings of a rule.
+func (rs *ruleSet) info(name string) ruleInfo {
+	if info, ok := rs.infos[name]; ok {
+		return info
+	}
+	return ruleInfo{severity: config.DEFAULT_SEVERITY, category: config.DEFAULT_CATEGORY, cwe: categoryCWEs[config.DEFAULT_CATEGORY]}
+}
+
+// secret returns a finding of the rule, classified.
+func (rs *ruleSet) secret(hostname, name, value, confidence, source string) Secret {
+	info := rs.info(name)
+	return Secret{
+		ID:         fmt.Sprintf("%s:%s", hostname, value),
+		Hostname:   hostname,
+		Key:        name,
+		Value:      value,
+		Severity:   info.severity,
+		Category:   info.category,
+		CWE:        info.cwe,
+		Confidence: confidence,
+		Source:     source,
+	}
+}
+
+// confidence rates a match of the rule given the bytes right before it,
+// an empty confidence means the match failed validation. Validated matches
+// are certain, the others gain confidence from a keyword before them and,
+// for credentials, from looking random.
+func (r *rule) confidence(value string, before []byte) string {
+	if r.validate != nil {
+		if !r.validate(value) {
+			return ""
+		}
+		return "high"
+	}
+	score := 1
+	if r.category == "credential" {
+		score = 0
+		if entropy(value) >= highEntropy {
+			score++
+		}
+	}
+	if len(r.keywords) > 0 {
+		before = bytes.ToLower(before)
+		for _, k := range r.keywords {
+			if bytes.Contains(before, k) {
+				score++
+				break
+			}
+		}
+	}
+	return config.Confidences[len(config.Confidences)-1-min(score, len(config.Confidences)-1)]
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var e float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
+
+// validJWT reports whether the header of the token decodes to a json object
+// naming its algorithm.
+func validJWT(value string) bool {
+	header, _, _ := strings.Cut(value, ".")
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(header, "="))
+	if err != nil {
+		return false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["alg"]
+	return ok
+}
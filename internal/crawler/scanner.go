@@ -0,0 +1,98 @@
+package crawler
+
+import "time"
+
+const (
+	scanChunkSize = 32 * 1024
+	// scanOverlap is kept from the previous chunk when scanning the next
+	// one, matches up to this length are caught across chunk boundaries.
+	scanOverlap = 4 * 1024
+)
+
+type match struct {
+	key        string
+	value      string
+	confidence string
+}
+
+// streamScanner runs the rules over a stream one window at a time. Every
+// window is made of the unscanned tail of the previous window followed by
+// the new bytes, a match is reported by the window it starts in as long as
+// at least scanOverlap bytes follow it, so it is never cut by the window end.
+type streamScanner struct {
+	rules   *ruleSet
+	window  []byte
+	offset  int64              // stream offset of the window start
+	lastEnd map[int]int64      // stream offset of the end of the last match per rule
+	seen    map[match]struct{} // by key and value
+	matches []match
+	busy    time.Duration // spent running the rules
+}
+
+func newStreamScanner(rules *ruleSet, buf []byte) *streamScanner {
+	return &streamScanner{
+		rules:   rules,
+		window:  buf[:0],
+		lastEnd: map[int]int64{},
+		seen:    map[match]struct{}{},
+	}
+}
+
+func (s *streamScanner) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		room := scanChunkSize + scanOverlap - len(s.window)
+		if room > len(p) {
+			room = len(p)
+		}
+		s.window = append(s.window, p[:room]...)
+		p = p[room:]
+		if len(s.window) == scanChunkSize+scanOverlap {
+			s.scan(false)
+		}
+	}
+	return n, nil
+}
+
+// flush scans whatever is left in the window, it must be called once the
+// stream has been fully written.
+func (s *streamScanner) flush() []match {
+	s.scan(true)
+	return s.matches
+}
+
+func (s *streamScanner) scan(final bool) {
+	started := time.Now()
+	defer func() { s.busy += time.Since(started) }()
+	limit := len(s.window)
+	if !final {
+		limit -= scanOverlap
+	}
+	for i, r := range s.rules.rules {
+		for _, loc := range r.re.FindAllIndex(s.window, -1) {
+			start, end := loc[0], loc[1]
+			if start >= limit {
+				break // owned by the next window
+			}
+			if s.offset+int64(start) < s.lastEnd[i] || start == end {
+				continue // overlaps a match reported by a previous window
+			}
+			s.lastEnd[i] = s.offset + int64(end)
+			m := match{key: r.name, value: string(s.window[start:end])}
+			if _, ok := s.seen[m]; ok {
+				continue
+			}
+			s.seen[m] = struct{}{}
+			if m.confidence = r.confidence(m.value, s.window[max(start-keywordDistance, 0):start]); len(m.confidence) == 0 {
+				continue
+			}
+			s.matches = append(s.matches, m)
+		}
+	}
+	if final {
+		return
+	}
+	tail := copy(s.window, s.window[limit:])
+	s.window = s.window[:tail]
+	s.offset += int64(limit)
+}
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"github.com/got-many-wheels/spoderman/internal/logger"
+)
+
+type scopeRule struct {
+	name       string
+	include    bool
+	schemes    []string
+	ports      []string
+	path       string
+	pathRegex  *regexp.Regexp
+	query      []string
+	extensions []string
+}
+
+// scopeFilter applies the scope rules with first match semantics, logging
+// which rule dropped an url.
+type scopeFilter struct {
+	rules  []scopeRule
+	logger *logger.Logger
+}
+
+func newScopeFilter(logger *logger.Logger, rules []config.ScopeRule) (*scopeFilter, error) {
+	sf := &scopeFilter{logger: logger}
+	for i, r := range rules {
+		rule := scopeRule{
+			name:  r.Name,
+			path:  r.Path,
+			query: r.Query,
+		}
+		if len(rule.name) == 0 {
+			rule.name = fmt.Sprintf("#%d", i+1)
+		}
+		switch strings.ToLower(r.Action) {
+		case "include":
+			rule.include = true
+		case "exclude":
+		default:
+			return nil, fmt.Errorf("scope rule %s: unsupported action %q", rule.name, r.Action)
+		}
+		for _, s := range r.Scheme {
+			rule.schemes = append(rule.schemes, strings.ToLower(s))
+		}
+		rule.ports = r.Port
+		for _, ext := range r.Extensions {
+			rule.extensions = append(rule.extensions, "."+strings.TrimPrefix(strings.ToLower(ext), "."))
+		}
+		if len(r.Path) > 0 {
+			if err := checkGlob(r.Path); err != nil {
+				return nil, fmt.Errorf("scope rule %s: invalid path glob %q: %w", rule.name, r.Path, err)
+			}
+		}
+		if len(r.PathRegex) > 0 {
+			re, err := regexp.Compile(r.PathRegex)
+			if err != nil {
+				return nil, fmt.Errorf("scope rule %s: %w", rule.name, err)
+			}
+			rule.pathRegex = re
+		}
+		sf.rules = append(sf.rules, rule)
+	}
+	return sf, nil
+}
+
+func (s *scopeFilter) allow(u string) bool {
+	uf, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	for _, r := range s.rules {
+		if !r.match(uf) {
+			continue
+		}
+		if !r.include {
+			s.logger.Debug().Msg(fmt.Sprintf("Dropping %s, excluded by scope rule %s", u, r.name))
+		}
+		return r.include
+	}
+	return true
+}
+
+func (r *scopeRule) match(u *url.URL) bool {
+	if len(r.schemes) > 0 && !slices.Contains(r.schemes, strings.ToLower(u.Scheme)) {
+		return false
+	}
+	if len(r.ports) > 0 && !slices.Contains(r.ports, urlPort(u)) {
+		return false
+	}
+	p := u.EscapedPath()
+	if len(p) == 0 {
+		p = "/"
+	}
+	if len(r.path) > 0 && !matchGlob(r.path, p) {
+		return false
+	}
+	if r.pathRegex != nil && !r.pathRegex.MatchString(p) {
+		return false
+	}
+	if len(r.query) > 0 {
+		q := u.Query()
+		for _, name := range r.query {
+			if !q.Has(name) {
+				return false
+			}
+		}
+	}
+	if len(r.extensions) > 0 && !slices.Contains(r.extensions, strings.ToLower(path.Ext(u.Path))) {
+		return false
+	}
+	return true
+}
+
+// urlPort returns the explicit port of the url or the default port of its
+// scheme.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); len(port) > 0 {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
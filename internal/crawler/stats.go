@@ -0,0 +1,310 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"github.com/got-many-wheels/spoderman/internal/logger"
+)
+
+// progressTopN is the number of hosts, error classes and rules listed on a
+// line of the live progress view.
+const progressTopN = 5
+
+// crawlStats counts what the workers go through, it drives the progress view
+// and the summary printed once the crawl is done. A nil crawlStats counts
+// nothing.
+type crawlStats struct {
+	started time.Time
+	pages   atomic.Int64 // responses received
+	bytes   atomic.Int64 // body bytes read
+
+	mu       sync.Mutex
+	inFlight map[string]int // requests being fetched per host
+	errors   map[string]int64
+}
+
+func newCrawlStats() *crawlStats {
+	return &crawlStats{
+		started:  time.Now(),
+		inFlight: map[string]int{},
+		errors:   map[string]int64{},
+	}
+}
+
+// begin records a request to host, end must be called once it is done.
+func (s *crawlStats) begin(host string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[host]++
+}
+
+func (s *crawlStats) end(host string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight[host]--; s.inFlight[host] <= 0 {
+		delete(s.inFlight, host)
+	}
+}
+
+// fetched records a response and the bytes read of its body.
+func (s *crawlStats) fetched(size int64) {
+	if s == nil {
+		return
+	}
+	s.pages.Add(1)
+	s.bytes.Add(size)
+}
+
+func (s *crawlStats) fail(class string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[class]++
+}
+
+// errorClass groups request errors by their cause.
+func errorClass(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "connection"
+	case strings.Contains(err.Error(), "tls:"), strings.Contains(err.Error(), "x509:"):
+		return "tls"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection"
+	}
+	return "other"
+}
+
+// statusClass groups unsuccessful responses by their status code class.
+func statusClass(status int) string {
+	return fmt.Sprintf("http_%dxx", status/100)
+}
+
+// snapshot is the state of the crawl at some point.
+type snapshot struct {
+	elapsed  time.Duration
+	pages    int64
+	bytes    int64
+	queued   int
+	inFlight map[string]int
+	errors   map[string]int64
+	findings map[string]int64
+}
+
+func (s *crawlStats) snapshot(jq *jobQueue) snapshot {
+	s.mu.Lock()
+	snap := snapshot{
+		elapsed:  time.Since(s.started),
+		pages:    s.pages.Load(),
+		bytes:    s.bytes.Load(),
+		inFlight: maps.Clone(s.inFlight),
+		errors:   maps.Clone(s.errors),
+	}
+	s.mu.Unlock()
+	snap.queued, snap.findings = jq.progress()
+	return snap
+}
+
+func (snap snapshot) rate() float64 {
+	if snap.elapsed <= 0 {
+		return 0
+	}
+	return float64(snap.pages) / snap.elapsed.Seconds()
+}
+
+// eta approximates the time left from the queue size and the current rate,
+// pages discovered later aren't accounted for.
+func (snap snapshot) eta() time.Duration {
+	rate := snap.rate()
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(snap.queued) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// progress shows how the crawl is going: a live view kept below the logs on
+// terminals, progress log lines otherwise.
+type progress struct {
+	stats    *crawlStats
+	jq       *jobQueue
+	logger   *logger.Logger
+	interval time.Duration
+
+	mu    sync.Mutex
+	lines []string // live view
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// newProgress returns the progress of the crawl, the summary is printed even
+// when the updates are disabled.
+func newProgress(p *config.Progress, stats *crawlStats, jq *jobQueue, logger *logger.Logger) *progress {
+	pr := &progress{
+		stats:  stats,
+		jq:     jq,
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if p == nil || p.Enabled == nil || !*p.Enabled {
+		return pr
+	}
+	if logger.IsTerminal() {
+		pr.interval = time.Second
+	} else if p.Interval != nil {
+		pr.interval = time.Duration(*p.Interval) * time.Second
+	}
+	return pr
+}
+
+func (p *progress) start() {
+	if p.interval <= 0 {
+		close(p.done)
+		return
+	}
+	p.logger.SetOverlay(p.view)
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report(p.stats.snapshot(p.jq))
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// finish stops the progress updates and prints the summary of the crawl.
+func (p *progress) finish() {
+	close(p.stop)
+	<-p.done
+	p.logger.SetOverlay(nil)
+	snap := p.stats.snapshot(p.jq)
+	if !p.logger.IsTerminal() {
+		p.logger.Info().
+			Dur("elapsed", snap.elapsed).
+			Int64("pages", snap.pages).
+			Float64("pages_per_second", snap.rate()).
+			Int64("bytes", snap.bytes).
+			Any("errors", snap.errors).
+			Any("findings", snap.findings).
+			Msg("Crawl summary")
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Crawl summary\n")
+	fmt.Fprintf(&b, "  elapsed     %s\n", snap.elapsed.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  pages       %d (%.1f/s)\n", snap.pages, snap.rate())
+	fmt.Fprintf(&b, "  downloaded  %s\n", formatBytes(snap.bytes))
+	fmt.Fprintf(&b, "  errors      %s\n", formatCounts(snap.errors, 0))
+	fmt.Fprintf(&b, "  findings    %s\n", formatCounts(snap.findings, 0))
+	io.WriteString(os.Stderr, b.String())
+}
+
+func (p *progress) report(snap snapshot) {
+	if !p.logger.IsTerminal() {
+		p.logger.Info().
+			Dur("elapsed", snap.elapsed).
+			Int64("pages", snap.pages).
+			Float64("pages_per_second", snap.rate()).
+			Int("queued", snap.queued).
+			Int("in_flight", sumCounts(snap.inFlight)).
+			Int64("bytes", snap.bytes).
+			Any("errors", snap.errors).
+			Any("findings", snap.findings).
+			Dur("eta", snap.eta()).
+			Msg("Crawl progress")
+		return
+	}
+	p.mu.Lock()
+	p.lines = []string{
+		fmt.Sprintf("elapsed %s  %.1f pages/s  eta ~%s", snap.elapsed.Round(time.Second), snap.rate(), snap.eta()),
+		fmt.Sprintf("pages %d  queued %d  in flight %d  downloaded %s", snap.pages, snap.queued, sumCounts(snap.inFlight), formatBytes(snap.bytes)),
+		"in flight  " + formatCounts(snap.inFlight, progressTopN),
+		"errors     " + formatCounts(snap.errors, progressTopN),
+		"findings   " + formatCounts(snap.findings, progressTopN),
+	}
+	p.mu.Unlock()
+	p.logger.Redraw()
+}
+
+func (p *progress) view() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lines
+}
+
+func sumCounts[V int | int64](counts map[string]V) int {
+	var n int
+	for _, v := range counts {
+		n += int(v)
+	}
+	return n
+}
+
+// formatCounts lists the counts from the highest, limited to the top n
+// unless n is 0.
+func formatCounts[V int | int64](counts map[string]V, n int) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+	keys := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		if counts[a] != counts[b] {
+			return int(counts[b] - counts[a])
+		}
+		return strings.Compare(a, b)
+	})
+	var parts []string
+	for i, k := range keys {
+		if n > 0 && i == n {
+			parts = append(parts, fmt.Sprintf("+%d more", len(keys)-n))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", k, counts[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/got-many-wheels/spoderman/internal/crawler"
+
+// newTracer returns the tracer of the job spans and the function flushing
+// the spans left to export. Spans are dropped unless an OTLP endpoint is
+// configured.
+func newTracer(t *config.Tracing) (trace.Tracer, func(context.Context) error, error) {
+	if t == nil || len(t.Endpoint) == 0 {
+		return noop.NewTracerProvider().Tracer(tracerName), func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(t.Endpoint),
+		otlptracehttp.WithHeaders(t.Headers),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	ratio := config.DEFAULT_TRACING_SAMPLE_RATIO
+	if t.SampleRatio != nil {
+		ratio = *t.SampleRatio
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "spoderman"))),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(ratio)),
+	)
+	return tp.Tracer(tracerName), tp.Shutdown, nil
+}
+
+// startJobSpan starts the span of a job. Every page gets its own trace so
+// traces stay small, the span links to the span of the page the url was
+// found on.
+func (c *Crawler) startJobSpan(ctx context.Context, j job) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("url.full", j.url),
+			attribute.Int("crawl.depth", j.depth),
+			attribute.Bool("crawl.probe", j.probe),
+		),
+	}
+	if j.parent.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: j.parent}))
+	}
+	return c.tracer.Start(ctx, "job", opts...)
+}
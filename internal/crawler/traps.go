@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+var (
+	numericSegment = regexp.MustCompile(`^[0-9]+$`)
+	idSegment      = regexp.MustCompile(`^(?i)([0-9a-f]{8,}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+)
+
+// trapDetector recognizes urls generated without end, such as infinite
+// calendars or relative links resolving into ever growing paths.
+type trapDetector struct {
+	mu                  sync.Mutex
+	maxRepeatedSegments int
+	maxUrlsPerPattern   int
+	patterns            map[string]int
+}
+
+func newTrapDetector(t *config.Traps) *trapDetector {
+	td := &trapDetector{patterns: map[string]int{}}
+	if t == nil {
+		return td
+	}
+	if t.MaxRepeatedSegments != nil {
+		td.maxRepeatedSegments = *t.MaxRepeatedSegments
+	}
+	if t.MaxUrlsPerPattern != nil {
+		td.maxUrlsPerPattern = *t.MaxUrlsPerPattern
+	}
+	return td
+}
+
+// trapped reports why the canonical url u looks like a crawler trap, or an
+// empty string when it doesn't. It should be called once per unique url.
+func (td *trapDetector) trapped(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+
+	if td.maxRepeatedSegments > 0 {
+		seen := map[string]int{}
+		for _, s := range segments {
+			if len(s) == 0 {
+				continue
+			}
+			seen[s]++
+			if seen[s] > td.maxRepeatedSegments {
+				return fmt.Sprintf("path segment %q repeated more than %d times", s, td.maxRepeatedSegments)
+			}
+		}
+	}
+
+	if td.maxUrlsPerPattern > 0 {
+		pattern := urlPattern(parsed, segments)
+		td.mu.Lock()
+		defer td.mu.Unlock()
+		td.patterns[pattern]++
+		if td.patterns[pattern] > td.maxUrlsPerPattern {
+			return fmt.Sprintf("more than %d urls matching %s", td.maxUrlsPerPattern, pattern)
+		}
+	}
+	return ""
+}
+
+// urlPattern returns the shape of the url, with numbers and ids replaced by
+// placeholders and only the names of the query parameters kept.
+func urlPattern(u *url.URL, segments []string) string {
+	shape := make([]string, len(segments))
+	for i, s := range segments {
+		switch {
+		case numericSegment.MatchString(s):
+			shape[i] = "{n}"
+		case idSegment.MatchString(s):
+			shape[i] = "{id}"
+		default:
+			shape[i] = s
+		}
+	}
+	pattern := u.Host + "/" + strings.Join(shape, "/")
+
+	q := u.Query()
+	if len(q) > 0 {
+		keys := make([]string, 0, len(q))
+		for k := range q {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pattern += "?" + strings.Join(keys, "&")
+	}
+	return pattern
+}
@@ -0,0 +1,319 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// warcSensitiveHeaders of the requests are masked unless the run is
+// unredacted, archives must not hold the credentials of the crawler. The
+// values of the cookies set by the responses are masked as well.
+var warcSensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// warcWriter writes the request and response of every fetched page to
+// gzipped WARC files, a new file is started once maxSize is reached. A nil
+// warcWriter writes nothing.
+type warcWriter struct {
+	dir           string
+	maxSize       int64
+	redactHeaders bool
+	prefix        string // file names are <prefix>-<seq>.warc.gz
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	seq  int
+}
+
+func newWarcWriter(w *config.Warc, redactHeaders bool) (*warcWriter, error) {
+	if w == nil || len(w.Dir) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(w.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create warc directory: %w", err)
+	}
+	ww := &warcWriter{
+		dir:           w.Dir,
+		redactHeaders: redactHeaders,
+		prefix:        "spoderman-" + time.Now().UTC().Format("20060102150405"),
+	}
+	if w.MaxSize != nil {
+		ww.maxSize = *w.MaxSize
+	}
+	return ww, nil
+}
+
+// newRecordID returns a record id, ids of responses are known before their
+// record is written so findings can reference them.
+func (w *warcWriter) newRecordID() string {
+	if w == nil {
+		return ""
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// writeExchange writes the request of the response and the response itself,
+// body is what was read of the response body.
+func (w *warcWriter) writeExchange(resp *response, body []byte, truncated bool, responseID string) error {
+	if w == nil {
+		return nil
+	}
+	req := resp.Request
+	now := time.Now().UTC().Format(time.RFC3339)
+	target := req.URL.String()
+
+	var reqBlock bytes.Buffer
+	fmt.Fprintf(&reqBlock, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&reqBlock, "Host: %s\r\n", host)
+	header := req.Header.Clone()
+	if w.redactHeaders {
+		for _, name := range warcSensitiveHeaders {
+			if len(header.Get(name)) > 0 {
+				header.Set(name, "[redacted]")
+			}
+		}
+	}
+	header.Write(&reqBlock)
+	reqBlock.WriteString("\r\n")
+
+	var respBlock bytes.Buffer
+	fmt.Fprintf(&respBlock, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	respHeader := resp.Header
+	if w.redactHeaders && len(respHeader.Values("Set-Cookie")) > 0 {
+		respHeader = respHeader.Clone()
+		for i, v := range respHeader.Values("Set-Cookie") {
+			respHeader["Set-Cookie"][i] = redactSetCookie(v)
+		}
+	}
+	respHeader.Write(&respBlock)
+	respBlock.WriteString("\r\n")
+	respBlock.Write(body)
+
+	requestID := w.newRecordID()
+	respFields := [][2]string{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Date", now},
+		{"WARC-Target-URI", target},
+		{"WARC-Concurrent-To", requestID},
+		{"WARC-Payload-Digest", warcDigest(body)},
+	}
+	if truncated {
+		respFields = append(respFields, [2]string{"WARC-Truncated", "length"})
+	}
+	respFields = append(respFields, [2]string{"Content-Type", "application/http;msgtype=response"})
+	reqFields := [][2]string{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", requestID},
+		{"WARC-Date", now},
+		{"WARC-Target-URI", target},
+		{"WARC-Concurrent-To", responseID},
+		{"Content-Type", "application/http;msgtype=request"},
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.rotate(); err != nil {
+		return err
+	}
+	if err := w.writeRecord(reqFields, reqBlock.Bytes()); err != nil {
+		return err
+	}
+	return w.writeRecord(respFields, respBlock.Bytes())
+}
+
+// rotate starts a new file when there is none yet or the current one is
+// full. The lock must be held.
+func (w *warcWriter) rotate() error {
+	if w.f != nil && (w.maxSize <= 0 || w.size < w.maxSize) {
+		return nil
+	}
+	if w.f != nil {
+		if err := w.f.Close(); err != nil {
+			return err
+		}
+	}
+	w.seq++
+	name := fmt.Sprintf("%s-%05d.warc.gz", w.prefix, w.seq)
+	f, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create warc file: %w", err)
+	}
+	w.f, w.size = f, 0
+	info := "software: spoderman\r\nformat: WARC File Format 1.1\r\n"
+	return w.writeRecord([][2]string{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", w.newRecordID()},
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", name},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte(info))
+}
+
+// writeRecord writes the record as its own gzip member, as tools reading
+// .warc.gz files expect. The lock must be held.
+func (w *warcWriter) writeRecord(fields [][2]string, block []byte) error {
+	var rec bytes.Buffer
+	gz := gzip.NewWriter(&rec)
+	fmt.Fprintf(gz, "WARC/1.1\r\n")
+	for _, f := range fields {
+		fmt.Fprintf(gz, "%s: %s\r\n", f[0], f[1])
+	}
+	fmt.Fprintf(gz, "WARC-Block-Digest: %s\r\n", warcDigest(block))
+	fmt.Fprintf(gz, "Content-Length: %d\r\n\r\n", len(block))
+	gz.Write(block)
+	gz.Write([]byte("\r\n\r\n"))
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	n, err := w.f.Write(rec.Bytes())
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write warc record: %w", err)
+	}
+	return nil
+}
+
+func (w *warcWriter) close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Close()
+}
+
+// redactSetCookie masks the value of the cookie, its name and attributes
+// are kept.
+func redactSetCookie(v string) string {
+	pair, attrs, _ := strings.Cut(v, ";")
+	name, _, _ := strings.Cut(pair, "=")
+	v = name + "=[redacted]"
+	if len(attrs) > 0 {
+		v += ";" + attrs
+	}
+	return v
+}
+
+func warcDigest(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// readWarc calls fn with every http response archived in the WARC file at
+// src, gzipped or not, along with the id of its record.
+func readWarc(src string, fn func(resp *http.Response, record string) error) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br) // reads every member of the file
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tp := textproto.NewReader(bufio.NewReader(r))
+
+	for {
+		line, err := tp.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(line) == 0 {
+			continue // end of the previous record
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return fmt.Errorf("invalid warc record version %q", line)
+		}
+		fields, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return fmt.Errorf("invalid warc record: %w", err)
+		}
+		length, err := strconv.ParseInt(fields.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid warc record length: %w", err)
+		} else if length < 0 {
+			return fmt.Errorf("invalid warc record length %d", length)
+		}
+		// the block grows as it is read, a corrupt length can't allocate
+		// more than what the file holds
+		block, err := io.ReadAll(io.LimitReader(tp.R, length))
+		if err != nil {
+			return fmt.Errorf("truncated warc record: %w", err)
+		} else if int64(len(block)) < length {
+			return fmt.Errorf("truncated warc record: %d of %d bytes", len(block), length)
+		}
+		if fields.Get("WARC-Type") != "response" || !strings.HasPrefix(fields.Get("Content-Type"), "application/http") {
+			continue
+		}
+		req, err := http.NewRequest(http.MethodGet, fields.Get("WARC-Target-URI"), nil)
+		if err != nil {
+			continue
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), req)
+		if err != nil {
+			continue
+		}
+		// the body is cut short of its content length when it was truncated
+		resp.Body = io.NopCloser(&lenientReader{r: resp.Body})
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			if gz, err := gzip.NewReader(resp.Body); err == nil {
+				resp.Body = io.NopCloser(&lenientReader{r: gz})
+				resp.Header.Del("Content-Encoding")
+			}
+		}
+		if err := fn(resp, fields.Get("WARC-Record-ID")); err != nil {
+			return err
+		}
+	}
+}
+
+// lenientReader ends the body at its last byte instead of failing when it is
+// shorter than announced.
+type lenientReader struct {
+	r io.Reader
+}
+
+func (l *lenientReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -1,22 +1,43 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/got-many-wheels/spoderman/internal/config"
 	"github.com/phuslu/log"
 )
 
+// Components are the parts of the crawler whose level can be set apart.
+var Components = []string{"fetch", "parse", "scan", "queue", "notify"}
+
+// Access is the component of the access log records, one per response. It
+// is disabled unless enabled in the config.
+const Access = "access"
+
+// levelOff is above every level, loggers set to it log nothing.
+const levelOff = log.PanicLevel + 1
+
 type Logger struct {
-	log log.Logger
+	log     log.Logger
+	term    *terminalWriter // only set when logging to a terminal
+	file    *log.FileWriter
+	verbose bool
+	levels  map[string]log.Level // per component
 }
 
 func New(verbose bool) *Logger {
-	l := Logger{}
+	l := Logger{verbose: verbose, levels: map[string]log.Level{Access: levelOff}}
 	level := log.InfoLevel
 	if verbose {
 		level = log.TraceLevel
 	}
 	if log.IsTerminal(os.Stderr.Fd()) {
+		l.term = &terminalWriter{w: os.Stderr}
 		l.log = log.Logger{
 			Level:      level,
 			TimeFormat: "15:04:05",
@@ -25,6 +46,7 @@ func New(verbose bool) *Logger {
 				ColorOutput:    true,
 				QuoteString:    true,
 				EndWithMessage: true,
+				Writer:         l.term,
 			},
 		}
 	} else {
@@ -38,6 +60,78 @@ func New(verbose bool) *Logger {
 func (l *Logger) ToVerbose(v bool) {
 	newLogger := New(v)
 	l.log = newLogger.log
+	l.term = newLogger.term
+	l.verbose = newLogger.verbose
+}
+
+// Configure applies the levels and the log file of the config. The default
+// level is left to trace when verbose, component levels apply either way.
+func (l *Logger) Configure(c *config.Log) error {
+	if c == nil {
+		return nil
+	}
+	if len(c.Level) > 0 && !l.verbose {
+		level, err := parseLevel(c.Level)
+		if err != nil {
+			return err
+		}
+		l.log.Level = level
+	}
+	for name, lvl := range c.Components {
+		if !slices.Contains(Components, name) {
+			return fmt.Errorf("unknown log component %q, components are %s", name, strings.Join(Components, ", "))
+		}
+		level, err := parseLevel(lvl)
+		if err != nil {
+			return err
+		}
+		l.levels[name] = level
+	}
+	if c.Access != nil && *c.Access {
+		l.levels[Access] = log.InfoLevel
+	}
+	if len(c.File) > 0 {
+		l.file = &log.FileWriter{Filename: c.File, EnsureFolder: true}
+		if c.MaxSize != nil {
+			l.file.MaxSize = *c.MaxSize
+		}
+		if c.MaxBackups != nil {
+			l.file.MaxBackups = *c.MaxBackups
+		}
+		var stderr log.Writer = log.IOWriter{Writer: os.Stderr}
+		if l.log.Writer != nil {
+			stderr = l.log.Writer
+		}
+		l.log.Writer = &log.MultiEntryWriter{stderr, l.file}
+	}
+	return nil
+}
+
+// Component returns the logger of a part of the crawler, its records carry
+// the component name and follow its level when one is configured.
+func (l *Logger) Component(name string) *Logger {
+	c := *l
+	c.log.Context = log.NewContext(nil).Str("component", name).Value()
+	if level, ok := l.levels[name]; ok {
+		c.log.Level = level
+	}
+	return &c
+}
+
+// Close flushes the log file.
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+func parseLevel(s string) (log.Level, error) {
+	switch level := log.ParseLevel(s); level {
+	case log.TraceLevel, log.DebugLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel:
+		return level, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q, levels are trace, debug, info, warn and error", s)
 }
 
 func (l *Logger) Debug() *log.Entry {
@@ -55,3 +149,70 @@ func (l *Logger) Warn() *log.Entry {
 func (l *Logger) Error() *log.Entry {
 	return l.log.Error()
 }
+
+// IsTerminal reports whether the logs are written to a terminal.
+func (l *Logger) IsTerminal() bool {
+	return l.term != nil
+}
+
+// SetOverlay keeps the lines returned by overlay drawn below the logs, such
+// as a progress view, until it is set to nil. It is a no-op unless logging
+// to a terminal.
+func (l *Logger) SetOverlay(overlay func() []string) {
+	if l.term == nil {
+		return
+	}
+	l.term.mu.Lock()
+	defer l.term.mu.Unlock()
+	l.term.clear()
+	l.term.overlay = overlay
+	l.term.draw()
+}
+
+// Redraw draws the overlay again once what it shows has changed.
+func (l *Logger) Redraw() {
+	if l.term == nil {
+		return
+	}
+	l.term.mu.Lock()
+	defer l.term.mu.Unlock()
+	l.term.clear()
+	l.term.draw()
+}
+
+// terminalWriter writes the log lines above the overlay, which is erased
+// and drawn again around every line.
+type terminalWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	overlay func() []string
+	drawn   int // lines of the overlay currently on screen
+}
+
+func (t *terminalWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clear()
+	n, err := t.w.Write(p)
+	t.draw()
+	return n, err
+}
+
+func (t *terminalWriter) clear() {
+	if t.drawn > 0 {
+		fmt.Fprintf(t.w, "\033[%dA\033[J", t.drawn)
+		t.drawn = 0
+	}
+}
+
+func (t *terminalWriter) draw() {
+	if t.overlay == nil {
+		return
+	}
+	lines := t.overlay()
+	if len(lines) == 0 {
+		return
+	}
+	io.WriteString(t.w, strings.Join(lines, "\n")+"\n")
+	t.drawn = len(lines)
+}
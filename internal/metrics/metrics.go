@@ -0,0 +1,156 @@
+// Package metrics exposes the counters of a crawl in the Prometheus format,
+// so long running crawls can be observed.
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "spoderman"
+
+// Metrics serves the crawl metrics over http. Methods of a nil Metrics do
+// nothing, so that metrics can be left disabled.
+type Metrics struct {
+	server   *http.Server
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	bytes    prometheus.Counter
+	queue    prometheus.Gauge
+	workers  prometheus.Gauge
+	findings *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+}
+
+// Serve starts serving the metrics at addr under /metrics.
+func Serve(addr string) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Responses received, by status code and host.",
+		}, []string{"code", "host"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "request_errors_total",
+			Help:      "Requests that got no response, by error class and host.",
+		}, []string{"class", "host"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "fetch_duration_seconds",
+			Help:      "Time until the response headers were received, by host.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 10),
+		}, []string{"host"}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "response_bytes_total",
+			Help:      "Response body bytes read.",
+		}),
+		queue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_depth",
+			Help:      "Jobs waiting in the queue.",
+		}),
+		workers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_workers",
+			Help:      "Workers running a job.",
+		}),
+		findings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "findings_total",
+			Help:      "Distinct secrets found, by rule and severity.",
+		}, []string{"rule", "severity"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Requests sent again, by reason.",
+		}, []string{"reason"}),
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.errors, m.latency, m.bytes, m.queue, m.workers, m.findings, m.retries,
+	)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	m.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go m.server.Serve(ln) // returns once closed
+	return m, nil
+}
+
+// Request records a response of host and how long it took to arrive.
+func (m *Metrics) Request(host string, code int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(strconv.Itoa(code), host).Inc()
+	m.latency.WithLabelValues(host).Observe(d.Seconds())
+}
+
+// RequestError records a request to host that got no response.
+func (m *Metrics) RequestError(host, class string) {
+	if m == nil {
+		return
+	}
+	m.errors.WithLabelValues(class, host).Inc()
+}
+
+func (m *Metrics) Bytes(n int64) {
+	if m == nil {
+		return
+	}
+	m.bytes.Add(float64(n))
+}
+
+func (m *Metrics) QueueDepth(n int) {
+	if m == nil {
+		return
+	}
+	m.queue.Set(float64(n))
+}
+
+// WorkerBusy records a worker starting a job, the returned function records
+// it being done.
+func (m *Metrics) WorkerBusy() func() {
+	if m == nil {
+		return func() {}
+	}
+	m.workers.Inc()
+	return m.workers.Dec
+}
+
+func (m *Metrics) Finding(rule, severity string) {
+	if m == nil {
+		return
+	}
+	m.findings.WithLabelValues(rule, severity).Inc()
+}
+
+func (m *Metrics) Retry(reason string) {
+	if m == nil {
+		return
+	}
+	m.retries.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) Close() error {
+	if m == nil {
+		return nil
+	}
+	return m.server.Close()
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// email sends the batches as plain text mails through an SMTP server.
+type email struct {
+	addr string
+	host string
+	from string
+	to   []string
+	auth smtp.Auth
+}
+
+func newEmail(nc config.Notifier) (*email, error) {
+	if len(nc.SmtpAddr) == 0 || len(nc.From) == 0 || len(nc.To) == 0 {
+		return nil, errors.New("smtpAddr, from and to are required")
+	}
+	host, _, err := net.SplitHostPort(nc.SmtpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtpAddr: %w", err)
+	}
+	e := &email{addr: nc.SmtpAddr, host: host, from: nc.From, to: nc.To}
+	username, err := env(nc.UsernameEnv, "smtp username")
+	if err != nil {
+		return nil, err
+	}
+	password, err := env(nc.PasswordEnv, "smtp password")
+	if err != nil {
+		return nil, err
+	}
+	if len(username) > 0 {
+		e.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return e, nil
+}
+
+func (e *email) send(ctx context.Context, b Batch) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: spoderman: %d new findings\r\n", len(b.Findings))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, f := range b.Findings {
+		fmt.Fprintf(&msg, "[%s] %s on %s\r\n  value: %s\r\n  confidence: %s\r\n  category: %s (%s)\r\n  fingerprint: %s\r\n  source: %s\r\n\r\n", f.Severity, f.Rule, f.Host, f.Value, f.Confidence, f.Category, f.CWE, f.Fingerprint, f.Source)
+	}
+	err := e.mail(ctx, []byte(msg.String()))
+	// rejections by the server are final
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return permanentError{err}
+	}
+	return err
+}
+
+// mail works like smtp.SendMail, the connection is bound to ctx so a server
+// that stalls doesn't hold the channel past the send timeout.
+func (e *email) mail(ctx context.Context, msg []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if e.auth != nil {
+		if err := c.Auth(e.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,240 @@
+// Package notify pushes findings to webhooks, Slack and email as they are
+// found. Every notifier batches the findings it is sent and retries the
+// batches it fails to deliver.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+	"github.com/got-many-wheels/spoderman/internal/logger"
+)
+
+// sendTimeout bounds every delivery attempt.
+const sendTimeout = 30 * time.Second
+
+// Finding is a finding as notified, its value is redacted unless the run is
+// unredacted.
+type Finding struct {
+	Host        string `json:"host"`
+	Rule        string `json:"rule"`
+	Severity    string `json:"severity"`
+	Category    string `json:"category"`
+	Confidence  string `json:"confidence"`
+	CWE         string `json:"cwe"`
+	Value       string `json:"value"`
+	Fingerprint string `json:"fingerprint"`
+	Source      string `json:"source"`
+}
+
+// Batch is what a notifier sends at once, webhook templates are executed
+// over it.
+type Batch struct {
+	Findings []Finding
+}
+
+type sender interface {
+	send(ctx context.Context, b Batch) error
+}
+
+// permanentError is returned by senders for failures retrying won't fix.
+type permanentError struct {
+	error
+}
+
+// Notifiers dispatches the findings to every configured notifier. Methods of
+// a nil Notifiers do nothing.
+type Notifiers struct {
+	channels []*channel
+}
+
+func New(cfg *config.Notify, logger *logger.Logger) (*Notifiers, error) {
+	if cfg == nil || len(cfg.Notifiers) == 0 {
+		return nil, nil
+	}
+	n := &Notifiers{}
+	for i, nc := range cfg.Notifiers {
+		var s sender
+		var err error
+		switch nc.Type {
+		case "webhook":
+			s, err = newWebhook(nc)
+		case "slack":
+			s, err = newSlack(nc)
+		case "email":
+			s, err = newEmail(nc)
+		default:
+			err = fmt.Errorf("unknown type %q, types are webhook, slack and email", nc.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid notifier %d: %w", i+1, err)
+		}
+		minRank := len(config.Severities) - 1
+		if len(nc.MinSeverity) > 0 {
+			if minRank = slices.Index(config.Severities, nc.MinSeverity); minRank < 0 {
+				return nil, fmt.Errorf("invalid notifier %d: unknown severity %q", i+1, nc.MinSeverity)
+			}
+		}
+		ch := &channel{
+			name:      fmt.Sprintf("%s notifier %d", nc.Type, i+1),
+			sender:    s,
+			minRank:   minRank,
+			batchSize: max(*cfg.BatchSize, 1),
+			interval:  time.Duration(*cfg.Interval) * time.Second,
+			retries:   max(*cfg.Retries, 0),
+			logger:    logger,
+			wake:      make(chan struct{}, 1),
+			done:      make(chan struct{}),
+		}
+		go ch.run()
+		n.channels = append(n.channels, ch)
+	}
+	return n, nil
+}
+
+// Notify queues the finding for the notifiers it is severe enough for, it
+// never blocks.
+func (n *Notifiers) Notify(f Finding) {
+	if n == nil {
+		return
+	}
+	rank := slices.Index(config.Severities, f.Severity)
+	for _, ch := range n.channels {
+		if rank >= 0 && rank <= ch.minRank {
+			ch.add(f)
+		}
+	}
+}
+
+// Close delivers the queued findings and waits for the notifiers to be done.
+func (n *Notifiers) Close() {
+	if n == nil {
+		return
+	}
+	for _, ch := range n.channels {
+		ch.close()
+	}
+	for _, ch := range n.channels {
+		<-ch.done
+	}
+}
+
+// channel batches the findings of a notifier.
+type channel struct {
+	name      string
+	sender    sender
+	minRank   int // index in config.Severities of the least severe findings sent
+	batchSize int
+	interval  time.Duration
+	retries   int
+	logger    *logger.Logger
+
+	mu      sync.Mutex
+	pending []Finding
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func (ch *channel) add(f Finding) {
+	ch.mu.Lock()
+	ch.pending = append(ch.pending, f)
+	ch.mu.Unlock()
+	ch.signal()
+}
+
+func (ch *channel) close() {
+	ch.mu.Lock()
+	ch.closed = true
+	ch.mu.Unlock()
+	ch.signal()
+}
+
+func (ch *channel) signal() {
+	select {
+	case ch.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (ch *channel) state() (int, bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return len(ch.pending), ch.closed
+}
+
+// run sends a batch once it is full, once the oldest finding of the batch
+// waited for the interval or once the channel is closed.
+func (ch *channel) run() {
+	defer close(ch.done)
+	for {
+		n, closed := ch.state()
+		if n == 0 {
+			if closed {
+				return
+			}
+			<-ch.wake
+			continue
+		}
+		if n < ch.batchSize && !closed {
+			timer := time.NewTimer(ch.interval)
+		filling:
+			for {
+				select {
+				case <-ch.wake:
+					if n, closed := ch.state(); n >= ch.batchSize || closed {
+						break filling
+					}
+				case <-timer.C:
+					break filling
+				}
+			}
+			timer.Stop()
+		}
+
+		ch.mu.Lock()
+		size := min(len(ch.pending), ch.batchSize)
+		batch := Batch{Findings: slices.Clone(ch.pending[:size])}
+		ch.pending = ch.pending[size:]
+		ch.mu.Unlock()
+		ch.deliver(batch)
+	}
+}
+
+// deliver sends the batch, retrying with an exponential backoff.
+func (ch *channel) deliver(b Batch) {
+	var err error
+	for attempt := 0; attempt <= ch.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Second << (attempt - 1))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = ch.sender.send(ctx, b)
+		cancel()
+		var permanent permanentError
+		if err == nil || errors.As(err, &permanent) {
+			break
+		}
+		ch.logger.Debug().Err(err).Msg(fmt.Sprintf("Failed to send %d findings with the %s, attempt %d", len(b.Findings), ch.name, attempt+1))
+	}
+	if err != nil {
+		ch.logger.Error().Err(err).Msg(fmt.Sprintf("Dropped %d findings the %s failed to send", len(b.Findings), ch.name))
+	}
+}
+
+func env(name, what string) (string, error) {
+	if len(name) == 0 {
+		return "", nil
+	}
+	v := os.Getenv(name)
+	if len(v) == 0 {
+		return "", fmt.Errorf("environment variable %s holding the %s is empty", name, what)
+	}
+	return v, nil
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// templateFuncs are available to webhook templates, json encodes a value
+// so it can be embedded in the body.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	},
+}
+
+// webhook posts the batches as json, {"findings": [...]} unless a template
+// is configured.
+type webhook struct {
+	url     string
+	headers map[string]string
+	tmpl    *template.Template
+	client  *http.Client
+}
+
+func newWebhook(nc config.Notifier) (*webhook, error) {
+	if len(nc.Url) == 0 {
+		return nil, errors.New("url is required")
+	}
+	w := &webhook{url: nc.Url, headers: nc.Headers, client: &http.Client{}}
+	if len(nc.Template) > 0 {
+		tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(nc.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		w.tmpl = tmpl
+	}
+	return w, nil
+}
+
+func (w *webhook) send(ctx context.Context, b Batch) error {
+	var body bytes.Buffer
+	if w.tmpl != nil {
+		if err := w.tmpl.Execute(&body, b); err != nil {
+			return permanentError{fmt.Errorf("failed to execute template: %w", err)}
+		}
+	} else if err := json.NewEncoder(&body).Encode(map[string]any{"findings": b.Findings}); err != nil {
+		return permanentError{err}
+	}
+	return w.post(ctx, body.Bytes())
+}
+
+// post sends the body, only rate limiting and server errors are worth
+// retrying.
+func (w *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook response error: %s", resp.Status)
+	}
+	return permanentError{fmt.Errorf("webhook response error: %s", resp.Status)}
+}
+
+// slack posts the batches as messages to a Slack compatible incoming
+// webhook.
+type slack struct {
+	hook *webhook
+}
+
+func newSlack(nc config.Notifier) (*slack, error) {
+	nc.Template = ""
+	hook, err := newWebhook(nc)
+	if err != nil {
+		return nil, err
+	}
+	return &slack{hook: hook}, nil
+}
+
+func (s *slack) send(ctx context.Context, b Batch) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "spoderman found %d new findings\n", len(b.Findings))
+	for _, f := range b.Findings {
+		fmt.Fprintf(&text, "• *%s* %s on %s: `%s` (%s)\n", f.Severity, f.Rule, f.Host, f.Value, f.Source)
+	}
+	body, err := json.Marshal(map[string]string{"text": text.String()})
+	if err != nil {
+		return permanentError{err}
+	}
+	return s.hook.post(ctx, body)
+}
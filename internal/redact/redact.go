@@ -0,0 +1,92 @@
+// Package redact masks the secret values written to the outputs. Every value
+// keeps a stable fingerprint so findings can still be deduplicated and
+// compared between runs once redacted.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+const (
+	ModeFull    = "full"
+	ModePartial = "partial"
+	ModeHash    = "hash"
+	ModeNone    = "none" // only set by the --unredacted flag
+
+	mask = "*"
+	// values whose kept runes would leave fewer than minMasked runes masked
+	// are fully masked.
+	minMasked = 4
+	// fingerprintSize is the number of hex characters of a fingerprint.
+	fingerprintSize = 16
+)
+
+type Redactor struct {
+	mode      string
+	keepFirst int
+	keepLast  int
+	salt      []byte
+}
+
+func New(r *config.Redaction) (*Redactor, error) {
+	rd := &Redactor{mode: config.DEFAULT_REDACTION_MODE}
+	if r == nil {
+		return rd, nil
+	}
+	if len(r.Mode) > 0 {
+		rd.mode = r.Mode
+	}
+	switch rd.mode {
+	case ModeFull, ModePartial, ModeHash, ModeNone:
+	default:
+		return nil, fmt.Errorf("unknown redaction mode %q", rd.mode)
+	}
+	if r.KeepFirst != nil {
+		rd.keepFirst = max(*r.KeepFirst, 0)
+	}
+	if r.KeepLast != nil {
+		rd.keepLast = max(*r.KeepLast, 0)
+	}
+	rd.salt = []byte(r.Salt)
+	return rd, nil
+}
+
+// Redact returns the value as it may be written to the outputs.
+func (rd *Redactor) Redact(value string) string {
+	switch rd.mode {
+	case ModeNone:
+		return value
+	case ModeHash:
+		return "sha256:" + rd.Fingerprint(value)
+	case ModePartial:
+		n := utf8.RuneCountInString(value)
+		if n-rd.keepFirst-rd.keepLast < minMasked {
+			break
+		}
+		runes := []rune(value)
+		return string(runes[:rd.keepFirst]) + strings.Repeat(mask, n-rd.keepFirst-rd.keepLast) + string(runes[n-rd.keepLast:])
+	}
+	return strings.Repeat(mask, utf8.RuneCountInString(value))
+}
+
+// Fingerprint identifies the value whatever the redaction mode, it is keyed
+// with the salt when one is configured.
+func (rd *Redactor) Fingerprint(value string) string {
+	var sum []byte
+	if len(rd.salt) > 0 {
+		mac := hmac.New(sha256.New, rd.salt)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		s := sha256.Sum256([]byte(value))
+		sum = s[:]
+	}
+	return hex.EncodeToString(sum)[:fingerprintSize]
+}
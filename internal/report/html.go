@@ -0,0 +1,112 @@
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+//go:embed report.html
+var htmlTemplate string
+
+// revealedRunes of a value are shown until the value is revealed.
+const revealedRunes = 4
+
+type htmlFinding struct {
+	Finding
+	Redacted  string
+	SourceUrl string // source without the path inside archives and repositories
+}
+
+// htmlGroup counts the findings of a host, a rule or a category per
+// severity.
+type htmlGroup struct {
+	Name   string
+	Counts map[string]int
+	Total  int
+}
+
+type htmlReport struct {
+	Run        *Run
+	Duration   time.Duration
+	Generated  time.Time
+	Severities []string
+	Counts     map[string]int
+	Hosts      []*htmlGroup
+	Rules      []*htmlGroup
+	Categories []*htmlGroup
+	Findings   []htmlFinding
+}
+
+func writeHTML(w io.Writer, run *Run, findings []Finding) error {
+	tmpl, err := template.New("report").Parse(htmlTemplate)
+	if err != nil {
+		return err
+	}
+	report := htmlReport{
+		Run:        run,
+		Generated:  time.Now(),
+		Severities: config.Severities,
+		Counts:     map[string]int{},
+	}
+	if !run.Started.IsZero() {
+		report.Duration = run.Finished.Sub(run.Started).Round(time.Second)
+	}
+	hosts, rules, categories := map[string]*htmlGroup{}, map[string]*htmlGroup{}, map[string]*htmlGroup{}
+	for _, f := range findings {
+		report.Counts[f.Severity]++
+		count(hosts, f.Host, f.Severity)
+		count(rules, f.Rule, f.Severity)
+		count(categories, f.Category, f.Severity)
+		report.Findings = append(report.Findings, htmlFinding{
+			Finding:   f,
+			Redacted:  redact(f.Value),
+			SourceUrl: strings.SplitN(f.Source, "!", 2)[0],
+		})
+	}
+	report.Hosts = sortedGroups(hosts)
+	report.Rules = sortedGroups(rules)
+	report.Categories = sortedGroups(categories)
+	return tmpl.Execute(w, report)
+}
+
+func count(groups map[string]*htmlGroup, name, severity string) {
+	g, ok := groups[name]
+	if !ok {
+		g = &htmlGroup{Name: name, Counts: map[string]int{}}
+		groups[name] = g
+	}
+	g.Counts[severity]++
+	g.Total++
+}
+
+// sortedGroups returns the groups with the most findings first.
+func sortedGroups(groups map[string]*htmlGroup) []*htmlGroup {
+	return slices.SortedFunc(maps.Values(groups), func(a, b *htmlGroup) int {
+		if a.Total != b.Total {
+			return b.Total - a.Total
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// redact keeps the first runes of the value, the whole value is shown when
+// revealed.
+func redact(value string) string {
+	if utf8.RuneCountInString(value) <= revealedRunes {
+		return strings.Repeat("•", utf8.RuneCountInString(value))
+	}
+	i := 0
+	for n := 0; n < revealedRunes; n++ {
+		_, size := utf8.DecodeRuneInString(value[i:])
+		i += size
+	}
+	return value[:i] + strings.Repeat("•", 8)
+}
@@ -0,0 +1,208 @@
+// Package report renders the results stored in an output directory, either
+// right after a crawl or later on.
+package report
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/got-many-wheels/spoderman/internal/config"
+)
+
+// RunFile describes the last crawl written to an output directory.
+const RunFile = "run.json"
+
+type Run struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Urls     []string  `json:"urls"`
+	Crawled  int64     `json:"crawled"`
+	Files    []string  `json:"files"` // result files, relative to the output directory
+}
+
+type Finding struct {
+	Host        string
+	Rule        string
+	Severity    string
+	Category    string
+	Confidence  string
+	CWE         string
+	Value       string // as written to the results, redacted unless the run was unredacted
+	Fingerprint string
+	Source      string
+	WarcRecord  string // record id of the archived response the finding was made in
+}
+
+// writers render the results in the formats reports can be generated in.
+var writers = map[string]func(w io.Writer, run *Run, findings []Finding) error{
+	"html": writeHTML,
+}
+
+// results are named after their host, a number is added to the names taken
+// by a previous run.
+var resultFileSuffix = regexp.MustCompile(`_\d+$`)
+
+func SaveRun(dir string, run Run) error {
+	raw, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, RunFile), raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	return nil
+}
+
+// Load reads the results of the last run written to dir, every result file
+// of the directory is read when the run summary is missing.
+func Load(dir string) (*Run, []Finding, error) {
+	run := &Run{}
+	raw, err := os.ReadFile(filepath.Join(dir, RunFile))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(raw, run); err != nil {
+			return nil, nil, fmt.Errorf("invalid run summary: %w", err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		matches, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range matches {
+			if name := filepath.Base(m); name != "duplicates.csv" {
+				run.Files = append(run.Files, name)
+			}
+		}
+	default:
+		return nil, nil, err
+	}
+
+	var findings []Finding
+	for _, name := range run.Files {
+		host := resultFileSuffix.ReplaceAllString(strings.TrimSuffix(name, ".csv"), "")
+		found, err := readResults(filepath.Join(dir, name), host)
+		if err != nil {
+			return nil, nil, err
+		}
+		findings = append(findings, found...)
+	}
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Or(
+			rank(config.Severities, a.Severity)-rank(config.Severities, b.Severity),
+			rank(config.Confidences, a.Confidence)-rank(config.Confidences, b.Confidence),
+		)
+	})
+	return run, findings, nil
+}
+
+// readResults reads a results csv, results written before severities and
+// categories existed get the default ones.
+func readResults(src, host string) ([]Finding, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid results %s: %w", src, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var findings []Finding
+	for _, record := range records[1:] {
+		finding := Finding{
+			Host:        host,
+			Rule:        field(record, "secret_key"),
+			Severity:    field(record, "severity"),
+			Category:    field(record, "category"),
+			Confidence:  field(record, "confidence"),
+			CWE:         field(record, "cwe"),
+			Value:       field(record, "value"),
+			Fingerprint: field(record, "fingerprint"),
+			Source:      field(record, "source"),
+			WarcRecord:  field(record, "warc_record"),
+		}
+		if len(finding.Severity) == 0 {
+			finding.Severity = config.DEFAULT_SEVERITY
+		}
+		if len(finding.Category) == 0 {
+			finding.Category = config.DEFAULT_CATEGORY
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// Generate writes the report of the results stored in dir to
+// dir/report.<format> and returns its path. When categories are given, only
+// their findings are reported, to dir/report-<categories>.<format>.
+func Generate(dir, format string, categories []string) (string, error) {
+	write, ok := writers[format]
+	if !ok {
+		return "", fmt.Errorf("unknown report format %q", format)
+	}
+	run, findings, err := Load(dir)
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, "report."+format)
+	if len(categories) > 0 {
+		for _, c := range categories {
+			if !slices.Contains(config.Categories, c) {
+				return "", fmt.Errorf("unknown category %q, categories are %s", c, strings.Join(config.Categories, ", "))
+			}
+		}
+		findings = slices.DeleteFunc(findings, func(f Finding) bool {
+			return !slices.Contains(categories, f.Category)
+		})
+		dst = filepath.Join(dir, "report-"+strings.Join(categories, "-")+"."+format)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := write(f, run, findings); err != nil {
+		return "", fmt.Errorf("failed to write %s report: %w", format, err)
+	}
+	return dst, nil
+}
+
+// ValidFormat reports whether reports can be generated in the format.
+func ValidFormat(format string) bool {
+	_, ok := writers[format]
+	return ok
+}
+
+// rank returns the index of v in levels, unknown values rank last.
+func rank(levels []string, v string) int {
+	if i := slices.Index(levels, v); i >= 0 {
+		return i
+	}
+	return len(levels)
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type Preset struct {
+	Name  string
+	Usage string
+	SQL   string // ? stands for the queried run, when present
+}
+
+// Presets are the common queries, every preset but runs is about a single
+// run.
+var Presets = []Preset{
+	{
+		Name:  "runs",
+		Usage: "every run with its number of pages and findings",
+		SQL: `SELECT r.id, r.started, r.finished, r.crawled,
+	(SELECT COUNT(*) FROM pages p WHERE p.run_id = r.id) AS pages,
+	(SELECT COUNT(*) FROM findings f WHERE f.run_id = r.id) AS findings
+FROM runs r ORDER BY r.id`,
+	},
+	{
+		Name:  "findings",
+		Usage: "findings of the run",
+		SQL: `SELECT severity, confidence, category, cwe, host, rule, value, fingerprint, source, warc_record
+FROM findings WHERE run_id = ?
+ORDER BY CASE severity WHEN 'critical' THEN 0 WHEN 'high' THEN 1 WHEN 'medium' THEN 2 WHEN 'low' THEN 3 ELSE 4 END,
+	CASE confidence WHEN 'high' THEN 0 WHEN 'medium' THEN 1 ELSE 2 END, host, rule`,
+	},
+	{
+		Name:  "findings-by-rule",
+		Usage: "number of findings and hosts per rule",
+		SQL: `SELECT rule, severity, COUNT(*) AS findings, COUNT(DISTINCT host) AS hosts
+FROM findings WHERE run_id = ? GROUP BY rule, severity ORDER BY findings DESC`,
+	},
+	{
+		Name:  "findings-by-host",
+		Usage: "number of findings and rules per host",
+		SQL: `SELECT host, COUNT(*) AS findings, COUNT(DISTINCT rule) AS rules
+FROM findings WHERE run_id = ? GROUP BY host ORDER BY findings DESC`,
+	},
+	{
+		Name:  "findings-by-category",
+		Usage: "number of findings, rules and hosts per category",
+		SQL: `SELECT category, COUNT(*) AS findings, COUNT(DISTINCT rule) AS rules, COUNT(DISTINCT host) AS hosts
+FROM findings WHERE run_id = ? GROUP BY category ORDER BY findings DESC`,
+	},
+	{
+		Name:  "pages",
+		Usage: "pages of the run",
+		SQL:   `SELECT url, status, content_type, depth, duration_ms, size, hash FROM pages WHERE run_id = ? ORDER BY fetched_at`,
+	},
+	{
+		Name:  "pages-by-status",
+		Usage: "number of pages per status code",
+		SQL:   `SELECT status, COUNT(*) AS pages FROM pages WHERE run_id = ? GROUP BY status ORDER BY status`,
+	},
+	{
+		Name:  "slowest-pages",
+		Usage: "the 20 slowest pages of the run",
+		SQL:   `SELECT url, status, duration_ms, size FROM pages WHERE run_id = ? ORDER BY duration_ms DESC LIMIT 20`,
+	},
+}
+
+func LookupPreset(name string) (Preset, bool) {
+	for _, p := range Presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// LatestRun returns the id of the last run, 0 when there is none.
+func (s *Store) LatestRun() (int64, error) {
+	var id sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(id) FROM runs`).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id.Int64, nil
+}
+
+// Query runs any query and returns its columns and rows as text.
+func (s *Store) Query(query string, args ...any) ([]string, [][]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var result [][]string
+	values := make([]any, len(columns))
+	for i := range values {
+		values[i] = new(any)
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(columns))
+		for i, v := range values {
+			switch v := (*v.(*any)).(type) {
+			case nil:
+				row[i] = ""
+			case []byte:
+				row[i] = string(v)
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		result = append(result, row)
+	}
+	return columns, result, rows.Err()
+}
@@ -0,0 +1,193 @@
+// Package store persists the results of every crawl in a SQLite database,
+// so that results of many runs accumulate in a single file.
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	started  TEXT NOT NULL,
+	finished TEXT,
+	urls     TEXT NOT NULL, -- json array of the target urls
+	crawled  INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS pages (
+	run_id       INTEGER NOT NULL REFERENCES runs(id),
+	url          TEXT NOT NULL,
+	status       INTEGER NOT NULL,
+	content_type TEXT NOT NULL,
+	depth        INTEGER NOT NULL,
+	duration_ms  INTEGER NOT NULL,
+	size         INTEGER NOT NULL,
+	hash         TEXT NOT NULL, -- sha256 of the body, empty when it wasn't read
+	fetched_at   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pages_run ON pages(run_id);
+CREATE TABLE IF NOT EXISTS findings (
+	run_id   INTEGER NOT NULL REFERENCES runs(id),
+	host     TEXT NOT NULL,
+	rule     TEXT NOT NULL,
+	severity TEXT NOT NULL,
+	value    TEXT NOT NULL, -- redacted unless the run was unredacted
+	source   TEXT NOT NULL,
+	fingerprint TEXT NOT NULL DEFAULT '',
+	warc_record TEXT NOT NULL DEFAULT '', -- response record the finding was made in
+	category    TEXT NOT NULL DEFAULT '',
+	confidence  TEXT NOT NULL DEFAULT '',
+	cwe         TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS findings_run ON findings(run_id);
+CREATE TABLE IF NOT EXISTS edges (
+	run_id   INTEGER NOT NULL REFERENCES runs(id),
+	from_url TEXT NOT NULL,
+	to_url   TEXT NOT NULL,
+	tag      TEXT NOT NULL,
+	text     TEXT NOT NULL,
+	UNIQUE (run_id, from_url, to_url, tag)
+);
+`
+
+type Page struct {
+	Url         string
+	Status      int
+	ContentType string
+	Depth       int
+	Duration    time.Duration
+	Size        int64
+	Hash        string
+}
+
+type Finding struct {
+	Host        string
+	Rule        string
+	Severity    string
+	Category    string
+	Confidence  string
+	CWE         string
+	Value       string
+	Fingerprint string
+	Source      string
+	WarcRecord  string
+}
+
+type Edge struct {
+	From string
+	To   string
+	Tag  string
+	Text string
+}
+
+// Store records a single run, a nil Store records nothing.
+type Store struct {
+	db    *sql.DB
+	runID int64
+}
+
+// Open opens or creates the database at src.
+func Open(src string) (*Store, error) {
+	db, err := sql.Open("sqlite", src)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite handles a single writer, workers take turns
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA journal_mode=WAL", "PRAGMA synchronous=NORMAL", "PRAGMA busy_timeout=5000", schema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open store %s: %w", src, err)
+		}
+	}
+	return &Store{db: db}, nil
+}
+
+// BeginRun records the start of a run, what is recorded next belongs to it.
+func (s *Store) BeginRun(started time.Time, urls []string) error {
+	if s == nil {
+		return nil
+	}
+	raw, err := json.Marshal(urls)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`INSERT INTO runs (started, urls) VALUES (?, ?)`, timestamp(started), string(raw))
+	if err != nil {
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+	s.runID, err = res.LastInsertId()
+	return err
+}
+
+// EndRun records the end of the run along with every finding.
+func (s *Store) EndRun(finished time.Time, crawled int64, findings []Finding) error {
+	if s == nil {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE runs SET finished = ?, crawled = ? WHERE id = ?`, timestamp(finished), crawled, s.runID); err != nil {
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO findings (run_id, host, rule, severity, category, confidence, cwe, value, fingerprint, source, warc_record) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, f := range findings {
+		if _, err := stmt.Exec(s.runID, f.Host, f.Rule, f.Severity, f.Category, f.Confidence, f.CWE, f.Value, f.Fingerprint, f.Source, f.WarcRecord); err != nil {
+			return fmt.Errorf("failed to record finding: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) AddPage(p Page) error {
+	if s == nil {
+		return nil
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO pages (run_id, url, status, content_type, depth, duration_ms, size, hash, fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.runID, p.Url, p.Status, p.ContentType, p.Depth, p.Duration.Milliseconds(), p.Size, p.Hash, timestamp(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record page %s: %w", p.Url, err)
+	}
+	return nil
+}
+
+// AddEdge records a link, links already recorded in the run are ignored.
+func (s *Store) AddEdge(e Edge) error {
+	if s == nil {
+		return nil
+	}
+	_, err := s.db.Exec(
+		`INSERT OR IGNORE INTO edges (run_id, from_url, to_url, tag, text) VALUES (?, ?, ?, ?, ?)`,
+		s.runID, e.From, e.To, e.Tag, e.Text,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record link %s -> %s: %w", e.From, e.To, err)
+	}
+	return nil
+}
+
+// timestamp formats times the way sqlite date functions read them.
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000")
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}